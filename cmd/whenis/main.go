@@ -7,17 +7,24 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata"
 
 	googlecal "google.golang.org/api/calendar/v3"
 
 	"github.com/MemeLabs/whenis/pkg/bot"
 	"github.com/MemeLabs/whenis/pkg/calendar"
 	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/config"
+	"github.com/MemeLabs/whenis/pkg/store"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2/google"
 )
 
 var googleCfgLocation = flag.String("config", "", "the location of you google oauth config")
+var settingsLocation = flag.String("settings", "", "the location of the whenis settings")
+var dataLocation = flag.String("data", "whenis.json", "the file whenis keeps its state in")
+var timezone = flag.String("timezone", "UTC", "the timezone used for events whose calendar has none")
 
 func main() {
 	flag.Parse()
@@ -29,6 +36,17 @@ func main() {
 		logrus.Fatal("missing oauth config (-h for details)")
 	}
 
+	settings, err := config.Load(*settingsLocation)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	calendar.DefaultLocation = loc
+
 	chat, err := chat.Connect(ctx, "wss://chat.strims.gg/ws", os.Getenv("STRIMS_JWT"))
 	if err != nil {
 		logrus.Fatal(err)
@@ -42,11 +60,15 @@ func main() {
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	cal, err := calendar.NewCalendar(ctx, cfg, os.Getenv("CAL_REFRESH_TOKEN"))
+	cal, err := calendar.NewCalendar(ctx, cfg, os.Getenv("CAL_REFRESH_TOKEN"), settings.Calendar)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	st, err := store.Open(*dataLocation)
 	if err != nil {
 		logrus.Fatal(err)
 	}
-	bot.NewBotForChat(ctx, chat, "whenis", cal)
+	bot.NewBotForChat(ctx, chat, "whenis", cal, st, settings.Bot)
 
 	signalchan := make(chan os.Signal, 2)
 	signal.Notify(signalchan, syscall.SIGTERM, os.Interrupt)
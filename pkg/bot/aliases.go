@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/search"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const aliasesBucket = "aliases"
+
+const aliasUsage = "use `-alias add f1 \"Formula 1\"`, `-alias list` or `-alias rm f1`"
+
+// alias replaces a word or query with what the calendars call it
+type alias struct {
+	Alias  string       `json:"alias"`
+	Target string       `json:"target"`
+	By     chat.Chatter `json:"by"`
+	At     time.Time    `json:"at"`
+}
+
+func aliasKey(s string) string {
+	return strings.Join(search.Tokenize(s), " ")
+}
+
+func (bot *Bot) manageAliases(msg chat.Message, args []string) {
+	args = util.SplitArgs(strings.Join(args, " "))
+	if len(args) == 0 {
+		bot.SendPriv(msg.Sender, aliasUsage)
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "list", "ls":
+		bot.listAliases(msg)
+		return
+	case "add", "rm", "remove", "del", "delete":
+	default:
+		bot.SendPriv(msg.Sender, aliasUsage)
+		return
+	}
+	if !bot.isMod(msg) {
+		bot.SendPriv(msg.Sender, "only mods can do that")
+		return
+	}
+
+	if strings.EqualFold(args[0], "add") {
+		if len(args) < 3 || aliasKey(args[1]) == "" || strings.TrimSpace(strings.Join(args[2:], " ")) == "" {
+			bot.SendPriv(msg.Sender, aliasUsage)
+			return
+		}
+		a := alias{
+			Alias:  args[1],
+			Target: strings.TrimSpace(strings.Join(args[2:], " ")),
+			By:     msg.Sender,
+			At:     time.Now(),
+		}
+		if err := bot.store.Put(aliasesBucket, aliasKey(a.Alias), a); err != nil {
+			logrus.Error("failed to save alias", err)
+			bot.SendPriv(msg.Sender, "could not save that")
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"mod":    msg.Sender,
+			"alias":  a.Alias,
+			"target": a.Target,
+		}).Info("added alias")
+		bot.SendPriv(msg.Sender, fmt.Sprintf("%s now means %s PepOk", a.Alias, a.Target))
+		return
+	}
+
+	if len(args) < 2 {
+		bot.SendPriv(msg.Sender, aliasUsage)
+		return
+	}
+	key := aliasKey(strings.Join(args[1:], " "))
+	if ok, _ := bot.store.Get(aliasesBucket, key, &alias{}); !ok {
+		bot.SendPriv(msg.Sender, "there is no such alias, see `-alias list`")
+		return
+	}
+	if err := bot.store.Delete(aliasesBucket, key); err != nil {
+		logrus.Error("failed to remove alias", err)
+		bot.SendPriv(msg.Sender, "could not remove that")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"mod":   msg.Sender,
+		"alias": key,
+	}).Info("removed alias")
+	bot.SendPriv(msg.Sender, "PepOk")
+}
+
+func (bot *Bot) aliases() map[string]alias {
+	aliases := make(map[string]alias)
+	err := bot.store.ForEach(aliasesBucket, func(key string, raw json.RawMessage) error {
+		var a alias
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return err
+		}
+		aliases[key] = a
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to load aliases", err)
+	}
+
+	return aliases
+}
+
+func (bot *Bot) listAliases(msg chat.Message) {
+	aliases := bot.aliases()
+	if len(aliases) == 0 {
+		bot.SendPriv(msg.Sender, "there are no aliases")
+		return
+	}
+
+	lines := make([]string, 0, len(aliases))
+	for _, a := range aliases {
+		lines = append(lines, fmt.Sprintf("%s → %s", a.Alias, a.Target))
+	}
+	sort.Strings(lines)
+	for _, part := range splitMessage(strings.Join(lines, " | "), defaultMaxLength, " | ") {
+		bot.SendPriv(msg.Sender, part)
+	}
+}
+
+// resolveAliases replaces a query that is an alias, or words of it that are, with their targets
+func (bot *Bot) resolveAliases(query string) string {
+	aliases := bot.aliases()
+	if len(aliases) == 0 {
+		return query
+	}
+	if a, ok := aliases[aliasKey(query)]; ok {
+		return a.Target
+	}
+
+	words := strings.Fields(query)
+	for i, w := range words {
+		if a, ok := aliases[aliasKey(w)]; ok && aliasKey(w) != "" {
+			words[i] = a.Target
+		}
+	}
+
+	return strings.Join(words, " ")
+}
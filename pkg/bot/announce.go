@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	announcedBucket = "announced"
+	// announceGrace is how late an announcement may still be posted
+	announceGrace = time.Minute * 2
+	// announcedRetention is how long announcements are remembered to prevent repeating them
+	announcedRetention = time.Hour * 24
+)
+
+// announceEvents posts starting events of the configured calendars in public chat
+func (bot *Bot) announceEvents(ctx context.Context, now time.Time) {
+	cfg := bot.cfg.Announce
+	if len(cfg.Calendars) == 0 {
+		return
+	}
+	bot.pruneAnnounced(now)
+
+	calendars := make(map[string]bool)
+	for _, ref := range cfg.Calendars {
+		id, err := bot.cal.ResolveCalendar(ctx, ref)
+		if err != nil {
+			logrus.Error("failed to resolve announced calendar", err)
+			continue
+		}
+		calendars[id] = true
+	}
+
+	leads := []time.Duration{0}
+	for _, lead := range cfg.LeadTimes {
+		leads = append(leads, time.Duration(lead))
+	}
+
+	for _, event := range bot.cal.Synced() {
+		if !calendars[bot.cal.CalendarOf(event)] || calendar.AllDay(event) {
+			continue
+		}
+		start := calendar.StartTime(event)
+		for _, lead := range leads {
+			at := start.Add(-lead)
+			if now.Before(at) || now.Sub(at) > announceGrace {
+				continue
+			}
+			key := fmt.Sprintf("%s@%d/%s", event.Id, start.Unix(), lead)
+			if ok, _ := bot.store.Get(announcedBucket, key, new(time.Time)); ok {
+				continue
+			}
+			// remember the announcement even if it is suppressed, it would only be late otherwise
+			if err := bot.store.Put(announcedBucket, key, now); err != nil {
+				logrus.Error("failed to save announcement", err)
+				continue
+			}
+
+			if reason := bot.suppressAnnouncement(now); reason != "" {
+				logrus.WithFields(logrus.Fields{
+					"event":  event.Summary,
+					"lead":   lead,
+					"reason": reason,
+				}).Info("suppressed announcement")
+				continue
+			}
+
+			if lead == 0 {
+				bot.Send(fmt.Sprintf("%s is starting now", event.Summary))
+			} else {
+				bot.Send(fmt.Sprintf("%s starts in %s", event.Summary, strings.TrimSpace(fmtDuration(lead))))
+			}
+			bot.announcements = append(bot.announcements, now)
+			logrus.WithFields(logrus.Fields{
+				"event": event.Summary,
+				"lead":  lead,
+			}).Info("announced event")
+		}
+	}
+}
+
+// suppressAnnouncement returns why an announcement should not be posted right now, if it should not
+func (bot *Bot) suppressAnnouncement(now time.Time) string {
+	cfg := bot.cfg.Announce
+
+	quiet, err := cfg.QuietHours.Contains(now)
+	if err != nil {
+		logrus.Error("invalid quiet hours", err)
+	}
+	if quiet {
+		return "quiet hours"
+	}
+
+	var recent []time.Time
+	for _, t := range bot.announcements {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	bot.announcements = recent
+
+	if len(recent) > 0 && now.Sub(recent[len(recent)-1]) < time.Duration(cfg.MinInterval) {
+		return "too soon after the last one"
+	}
+	if cfg.MaxPerHour > 0 && len(recent) >= cfg.MaxPerHour {
+		return "hourly limit reached"
+	}
+
+	return ""
+}
+
+// pruneAnnounced forgets announcements that can't be repeated anymore anyway
+func (bot *Bot) pruneAnnounced(now time.Time) {
+	err := bot.store.ForEach(announcedBucket, func(key string, raw json.RawMessage) error {
+		var t time.Time
+		if err := json.Unmarshal(raw, &t); err != nil || now.Sub(t) > announcedRetention {
+			return bot.store.Delete(announcedBucket, key)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to prune announcements", err)
+	}
+}
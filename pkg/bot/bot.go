@@ -4,39 +4,68 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	googlecal "google.golang.org/api/calendar/v3"
 
 	"github.com/MemeLabs/whenis/pkg/calendar"
 	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/store"
 	"github.com/sirupsen/logrus"
 )
 
+// requestTimeout bounds the time spent answering a single message
+const requestTimeout = time.Second * 15
+
+// sender delivers messages to chat
+type sender interface {
+	Send(message string) error
+	SendPriv(recipient chat.Chatter, message string) error
+}
+
 type Bot struct {
-	cal  *calendar.Calendar
-	chat *chat.Chat
+	cal   *calendar.Calendar
+	chat  *chat.Chat
+	out   sender
+	store *store.Store
+	cfg   Config
 
-	lastIDK time.Time
-	lastMsg string
-	emote   bool
+	// stateLock guards state shared by all chatters
+	stateLock sync.Mutex
+	lastMsg   string
+	emote     bool
+
+	lastSync time.Time
+
+	announcements []time.Time
+	limits        *limiter
+	choices       *choices
+	sessions      *sessions
 
 	name chat.Chatter
 
-	ongoingAdditions map[chat.Chatter]*eventEntry
+	additionsLock    sync.Mutex
+	ongoingAdditions map[chat.Chatter]*wizard
 }
 
-func NewBotForChat(ctx context.Context, c *chat.Chat, name string, cal *calendar.Calendar) *Bot {
+func NewBotForChat(ctx context.Context, c *chat.Chat, name string, cal *calendar.Calendar, st *store.Store, cfg Config) *Bot {
 	bot := &Bot{
 		chat:             c,
+		out:              c,
+		store:            st,
+		cfg:              cfg,
 		name:             chat.Chatter(name),
 		cal:              cal,
-		ongoingAdditions: make(map[chat.Chatter]*eventEntry),
+		ongoingAdditions: make(map[chat.Chatter]*wizard),
+		limits:           newLimiter(),
+		choices:          newChoices(),
+		sessions:         newSessions(),
 	}
 
 	go bot.handleMessages(ctx)
+	go bot.runScheduler(ctx)
 
 	return bot
 }
@@ -47,161 +76,299 @@ func (bot *Bot) handleMessages(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case msg := <-bot.chat.MessageChan:
+			bot.observeMod(msg)
 			if msg.Sender == bot.name || (!msg.Mentions(bot.name) && !msg.Private) {
 				continue
 			}
-			bot.process(msg)
+			bot.dispatch(ctx, msg)
 		}
 	}
 }
 
-func (bot *Bot) process(msg chat.Message) {
+func (bot *Bot) process(ctx context.Context, msg chat.Message) {
 	f := flag.NewFlagSet("whenis", flag.ContinueOnError)
 	list := f.Bool("list", false, "list all available calendars")
 	add := f.Bool("add", false, "add an event")
 	abort := f.Bool("abort", false, "stop an action")
+	back := f.Bool("back", false, "go back a step while adding an event")
+	multi := f.Int("multi", 0, "search for the next n events")
+	calName := f.String("cal", "", "the calendar to add an event to or follow")
+	ongoing := f.Bool("ongoing", false, "list ongoing events and their remaining time")
+	ending := f.Duration("ending", 0, "list ongoing events that end within the given duration")
+	tz := f.String("tz", "", "set your timezone, e.g. Europe/Berlin")
+	clock := f.String("clock", "", "show times with a 12 or 24 hour clock")
+	details := f.String("details", "", "include absolute times in replies (on, off or reset)")
+	showPrefs := f.Bool("prefs", false, "show your settings")
+	remind := f.Bool("remind", false, "get a message before an event starts, e.g. -remind Formula 1 10m before")
+	every := f.Bool("every", false, "with -remind, remind you of every occurrence of the event")
+	reminders := f.Bool("reminders", false, "list your reminders")
+	unremind := f.String("unremind", "", "remove a reminder by its id, or all of them")
+	follow := f.Bool("follow", false, "get a message when matching events are added, moved or cancelled")
+	following := f.Bool("following", false, "list what you follow")
+	unfollow := f.String("unfollow", "", "stop following by id, or everything")
+	mine := f.Bool("mine", false, "list the events you created")
+	edit := f.Bool("edit", false, "change an event you created, e.g. -edit abc123 title Movie Night")
+	del := f.String("delete", "", "delete an event you created by its id, with -on a single occurrence of it")
+	ban := f.Bool("ban", false, "mods: stop a chatter from adding events")
+	unban := f.Bool("unban", false, "mods: let a banned chatter add events again")
+	allow := f.Bool("allow", false, "mods: add a chatter to the allow list")
+	disallow := f.Bool("disallow", false, "mods: remove a chatter from the allow list")
+	lists := f.Bool("lists", false, "mods: show the ban and allow lists")
+	pending := f.Bool("pending", false, "mods: list events waiting for approval")
+	approve := f.String("approve", "", "mods: add a pending event by its id")
+	reject := f.Bool("reject", false, "mods: drop a pending event, e.g. -reject abc123 duplicate")
+	limits := f.Bool("limits", false, "mods: show the rate limits")
+	today := f.Bool("today", false, "list today's events")
+	tomorrow := f.Bool("tomorrow", false, "list tomorrow's events")
+	week := f.Bool("week", false, "list the events of the next 7 days")
+	on := f.String("on", "", "list the events of a day, e.g. -on friday or -on 2006-01-02")
+	at := f.String("at", "", "list the events taking place at a time, e.g. -at 21:00 CET")
+	last := f.Bool("last", false, "when an event last took place, e.g. -last Formula 1 or -last 30d Formula 1")
+	aliases := f.Bool("alias", false, "search aliases, e.g. -alias add f1 \"Formula 1\", -alias list or -alias rm f1")
 	_ = f.Parse(strings.Split(msg.WithoutNick(bot.name), " "))
 
+	// aborting is never limited, so chatters can always get out of a wizard
 	if *abort {
 		logrus.WithField("chatter", msg.Sender).Info("aborted action")
-		delete(bot.ongoingAdditions, msg.Sender)
+		bot.abortWizard(msg)
 		bot.SendPriv(msg.Sender, "PepOk")
 		return
 	}
-	if _, ok := bot.ongoingAdditions[msg.Sender]; ok {
-		bot.continueAddingEvent(msg)
+
+	cmd := commandOf(f)
+	if bot.addingEvent(msg.Sender) && (cmd == queryCommand || *back || *edit) {
+		cmd = wizardCommand
+	}
+	if !bot.admit(msg, cmd) {
+		return
+	}
+	if bot.continueAddingEvent(ctx, msg, *back, *edit, f.Args()) {
+		return
+	}
+	if *back {
+		bot.SendPriv(msg.Sender, "you are not adding an event, start with `-add`")
+		return
+	}
+	if bot.takeChoice(msg) {
+		return
+	}
+
+	switch {
+	case *ban, *unban:
+		bot.updateList(msg, bannedBucket, *ban, f.Args())
+		return
+	case *allow, *disallow:
+		bot.updateList(msg, allowedBucket, *allow, f.Args())
+		return
+	case *lists:
+		bot.sendLists(msg)
+		return
+	case *pending:
+		bot.listPending(msg)
+		return
+	case *approve != "":
+		bot.approveEvent(ctx, msg, *approve)
+		return
+	case *reject:
+		bot.rejectEvent(msg, f.Args())
+		return
+	case *limits:
+		bot.sendLimits(msg)
+		return
+	case *aliases:
+		bot.manageAliases(msg, f.Args())
 		return
 	}
 
 	if *add {
-		logrus.WithField("chatter", msg.Sender).Info("starting to add event")
-		bot.SendPriv(msg.Sender, "what should the title be? (you can stop adding the event any time using `-abort`)")
-		bot.ongoingAdditions[msg.Sender] = &eventEntry{}
+		if err := bot.mayCreate(msg); err != nil {
+			bot.SendPriv(msg.Sender, err.Error())
+			return
+		}
+		if len(f.Args()) > 0 {
+			bot.addOneShot(ctx, msg, f.Args(), *calName)
+			return
+		}
+		target, err := bot.cal.Target(ctx, *calName, string(msg.Sender))
+		if err != nil {
+			bot.SendPriv(msg.Sender, err.Error())
+			return
+		}
+		logrus.WithFields(logrus.Fields{
+			"chatter":  msg.Sender,
+			"calendar": target,
+		}).Info("starting to add event")
+		bot.startWizard(msg, target)
 		return
 	}
 
 	if *list {
-		bot.sendList(msg)
+		bot.sendList(ctx, msg)
+		return
+	}
+
+	if *tz != "" || *clock != "" || *details != "" {
+		bot.updatePrefs(msg, *tz, *clock, *details)
+		return
+	}
+	if *showPrefs {
+		bot.SendPriv(msg.Sender, bot.prefs(msg.Sender).String())
+		return
+	}
+
+	if *remind {
+		bot.addReminder(ctx, msg, f.Args(), *every)
+		return
+	}
+	if *reminders {
+		bot.listReminders(msg)
+		return
+	}
+	if *unremind != "" {
+		bot.removeReminder(msg, *unremind)
+		return
+	}
+
+	if *mine {
+		bot.listMine(msg)
+		return
+	}
+	if *edit {
+		bot.editEvent(ctx, msg, f.Args())
+		return
+	}
+	if *del != "" {
+		// flag parsing stops after the first word of the day, e.g. `-on next friday`
+		day := *on
+		if day != "" {
+			day = strings.TrimSpace(day + " " + strings.Join(f.Args(), " "))
+		}
+		bot.deleteEvent(ctx, msg, *del, day)
+		return
+	}
+
+	if *follow {
+		bot.follow(ctx, msg, strings.Join(f.Args(), " "), *calName)
+		return
+	}
+	if *following {
+		bot.listSubscriptions(msg)
+		return
+	}
+	if *unfollow != "" {
+		bot.unfollow(msg, *unfollow)
+		return
+	}
+
+	switch {
+	case *today || *tomorrow:
+		offset := 0
+		if *tomorrow {
+			offset = 1
+		}
+		bot.sendDay(ctx, msg, offset, "", strings.Join(f.Args(), " "))
+		return
+	case *on != "":
+		bot.sendDay(ctx, msg, 0, strings.TrimSpace(*on+" "+strings.Join(f.Args(), " ")), "")
+		return
+	case *week:
+		bot.sendWeek(ctx, msg, strings.Join(f.Args(), " "))
+		return
+	case *at != "":
+		bot.sendAt(ctx, msg, strings.TrimSpace(*at+" "+strings.Join(f.Args(), " ")))
+		return
+	case *last:
+		bot.lastQuery(ctx, msg, f.Args())
+		return
+	}
+
+	if *ongoing || *ending > 0 {
+		bot.sendOngoing(ctx, msg, *ending)
+		return
+	}
+
+	if *multi > 0 {
+		bot.multiQuery(ctx, msg, strings.Join(f.Args(), " "), *multi)
 		return
 	}
 
-	bot.simpleQuery(msg)
+	bot.simpleQuery(ctx, msg)
 }
 
-func (bot *Bot) sendList(msg chat.Message) {
+func (bot *Bot) sendList(ctx context.Context, msg chat.Message) {
 	logrus.WithField("chatter", msg.Sender).Info("got a list request")
 	var resp string
 
-	for _, name := range bot.cal.List() {
+	for _, name := range bot.cal.List(ctx) {
 		resp += fmt.Sprintf("`%s` ", name)
 	}
 
-	if msg.Private {
-		bot.SendPriv(msg.Sender, resp)
-	} else {
-		bot.Send(resp)
-	}
-}
-
-type eventEntry struct {
-	title          string
-	searchKeywords string
-	time           time.Time
-	duration       time.Duration
+	bot.reply(msg, resp)
 }
 
-func (bot *Bot) continueAddingEvent(msg chat.Message) {
-	// TODO: locking, logging
+func (bot *Bot) sendOngoing(ctx context.Context, msg chat.Message, within time.Duration) {
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"within":  within,
+	}).Info("got an ongoing request")
 
-	e, ok := bot.ongoingAdditions[msg.Sender]
-	if !ok {
+	var events []*googlecal.Event
+	var err error
+	if within > 0 {
+		events, err = bot.cal.EndingWithin(ctx, within)
+	} else {
+		events, err = bot.cal.OngoingEvents(ctx)
+	}
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to handle request", err)
+		bot.SendPriv(msg.Sender, err.Error())
 		return
 	}
 
-	msg.Data = strings.TrimSpace(msg.WithoutNick(bot.name))
-
-	if e.title == "" {
-		e.title = msg.Data
-		if badpfx := strings.HasPrefix(e.title, "!"); e.title == "" || badpfx {
-			bot.SendPriv(msg.Sender, "invalid title, please try again")
-			if badpfx {
-				bot.SendPriv(msg.Sender, "titles must not begin with '!'")
-				e.title = ""
-			}
-		} else {
-			bot.SendPriv(msg.Sender, "what search keywords should the event have?")
-		}
+	if len(events) == 0 {
+		bot.reply(msg, "nothing is going on right now"+partialNote(err))
 		return
 	}
-	if e.searchKeywords == "" {
-		e.searchKeywords = msg.Data
-		if e.searchKeywords == "" {
-			e.searchKeywords = "-"
-		}
-		bot.SendPriv(msg.Sender, "at what time does it start? acceptable formats are `2006-01-02T15:04:05Z` (RFC3339), `1630006096` (unix) or `in 2h5m`")
-		return
+
+	now := time.Now()
+	responses := make([]string, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, remainingResponse(event, now))
 	}
-	var err error
-	if e.time.IsZero() {
-		e.time, err = time.Parse(time.RFC3339, msg.Data)
-		if err == nil {
-			if e.time.Before(time.Now()) {
-				bot.SendPriv(msg.Sender, "thats in the past FeelsPepoMan ")
-				e.time = time.Time{}
-				return
-			}
-			bot.SendPriv(msg.Sender, "how long will the event last? provide the duration in the format `2h5m`")
-			return
-		}
-		unix, err := strconv.ParseInt(msg.Data, 10, 64)
-		if err == nil {
-			e.time = time.Unix(unix, 0)
-			if e.time.Before(time.Now()) {
-				bot.SendPriv(msg.Sender, "thats in the past FeelsPepoMan ")
-				e.time = time.Time{}
-				return
-			}
-			bot.SendPriv(msg.Sender, "how long will the event last? provide the duration in the format `2h5m`")
-			return
-		}
+	bot.reply(msg, strings.Join(responses, " | ")+partialNote(err))
+}
 
-		duration, err := time.ParseDuration(strings.TrimPrefix(msg.Data, "in "))
-		if err == nil {
-			e.time = time.Now().Add(duration)
-			bot.SendPriv(msg.Sender, "how long will the event last? provide the duration in the format `2h5m`")
-			return
-		}
+// partialNote marks replies that may be incomplete because some calendars could not be queried
+func partialNote(err error) string {
+	if calendar.IsPartial(err) {
+		return " (some calendars could not be reached)"
+	}
 
-		bot.SendPriv(msg.Sender, "I could not understand that, please try again.")
-		return
-	}
-	if e.duration == 0 {
-		e.duration, err = time.ParseDuration(msg.Data)
-		if err == nil {
-			err := bot.cal.AddEvent(string(msg.Sender), e.title, e.searchKeywords, e.time, e.duration)
-			if err == nil {
-				logrus.WithFields(logrus.Fields{
-					"chatter":     msg.Sender,
-					"title":       e.title,
-					"start":       e.time,
-					"end":         e.time.Add(e.duration),
-					"description": e.searchKeywords,
-				}).Info("added event")
-				bot.SendPriv(msg.Sender, "noted PepoG")
-			} else {
-				logrus.Error("failed to add event", err)
-				bot.SendPriv(msg.Sender, fmt.Sprintf("could not add event %v", err))
-			}
-			delete(bot.ongoingAdditions, msg.Sender)
-			return
-		}
-		e.duration = 0
-		bot.SendPriv(msg.Sender, "I could not understand that, please try again.")
-		return
+	return ""
+}
+
+// createEvent adds an event to the calendar and records its creator, it returns the id to manage it with
+func (bot *Bot) createEvent(ctx context.Context, creator chat.Chatter, e *eventEntry) (string, error) {
+	event, err := bot.cal.AddEvent(ctx, e.calendar, string(creator), e.title, e.searchKeywords, e.time, e.duration, e.recurrence)
+	if err != nil {
+		return "", err
 	}
+
+	id := bot.recordCreated(e.calendar, event, creator, e.recurrence)
+	logrus.WithFields(logrus.Fields{
+		"chatter":     creator,
+		"calendar":    e.calendar,
+		"title":       e.title,
+		"start":       e.time,
+		"end":         e.time.Add(e.duration),
+		"description": e.searchKeywords,
+		"id":          id,
+	}).Info("added event")
+
+	return id, nil
 }
 
-func (bot *Bot) simpleQuery(msg chat.Message) {
+func (bot *Bot) simpleQuery(ctx context.Context, msg chat.Message) {
 	logrus.WithFields(logrus.Fields{
 		"chatter": msg.Sender,
 		"query":   msg.WithoutNick(bot.name),
@@ -213,44 +380,104 @@ func (bot *Bot) simpleQuery(msg chat.Message) {
 		return
 	}
 
-	events, err := bot.cal.Query(msg.WithoutNick(bot.name), 1)
+	query := bot.resolveAliases(msg.WithoutNick(bot.name))
+	filter, structured, err := calendar.ParseFilter(query, time.Now(), bot.prefs(msg.Sender).location())
 	if err != nil {
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+
+	var events []*googlecal.Event
+	if structured {
+		events, err = bot.cal.Find(ctx, filter, 1)
+	} else {
+		// the local index ranks fuzzy matches, the calendar api finds events beyond the synced range
+		events = bot.cal.Search(query, maxChoices)
+		if len(events) == 0 {
+			events, err = bot.cal.Query(ctx, query, maxChoices)
+		}
+	}
+	if err != nil && !calendar.IsPartial(err) {
 		logrus.Error("failed to handle request", err)
 		bot.Send(err.Error())
 		return
 	}
+	note := partialNote(err)
 	var event *googlecal.Event
-	if len(events) == 0 {
-		event, err = bot.cal.QueryCalendarTitles(msg.WithoutNick(bot.name))
-		if err != nil {
+	if len(events) == 0 && !structured {
+		event, err = bot.cal.QueryCalendarTitles(ctx, query)
+		if err != nil && !calendar.IsPartial(err) {
 			logrus.Error("failed to handle request", err)
 			bot.Send(err.Error())
 			return
 		}
-	} else {
+		if note == "" {
+			note = partialNote(err)
+		}
+	} else if len(events) > 0 {
+		if series := bot.distinctSeries(events); len(series) > 1 && !structured {
+			bot.offerChoices(msg, series)
+			return
+		}
 		event = events[0]
 	}
 
 	if event == nil {
-		if bot.lastIDK.Add(time.Minute).After(time.Now()) {
-			bot.SendPriv(msg.Sender, "idk SHRUG")
-		} else {
-			if bot.emote {
-				bot.Send("idk SHRUG")
-			} else {
-				bot.Send("idk TANTIES")
-			}
-			bot.emote = !bot.emote
-			bot.lastIDK = time.Now() // TODO: locking
+		if msg.Private || !bot.publicAllowed("idk", bot.rateLimits().Idk) {
+			bot.SendPriv(msg.Sender, "idk SHRUG"+note)
+			return
 		}
+		// the idk limit is stricter than the one of public replies, so it is the only one checked
+		bot.Send(bot.idk() + note)
 		return
 	}
 
-	if msg.Private {
-		bot.SendPriv(msg.Sender, generateResponse(event))
+	bot.reply(msg, generateResponse(event, bot.prefs(msg.Sender))+note)
+}
+
+// maxMulti limits the amount of events listed by -multi
+const maxMulti = 10
+
+func (bot *Bot) multiQuery(ctx context.Context, msg chat.Message, query string, amount int) {
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"query":   query,
+		"amount":  amount,
+		"private": msg.Private,
+	}).Info("got multi request")
+
+	if amount > maxMulti {
+		amount = maxMulti
+	}
+	query = bot.resolveAliases(query)
+	filter, structured, err := calendar.ParseFilter(query, time.Now(), bot.prefs(msg.Sender).location())
+	if err != nil {
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+	var events []*googlecal.Event
+	if structured {
+		events, err = bot.cal.Find(ctx, filter, amount)
 	} else {
-		bot.Send(generateResponse(event))
+		events, err = bot.cal.Query(ctx, query, int64(amount))
+	}
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to handle request", err)
+		bot.Send(err.Error())
+		return
+	}
+
+	if len(events) == 0 {
+		bot.SendPriv(msg.Sender, "idk SHRUG"+partialNote(err))
+		return
+	}
+
+	p := bot.prefs(msg.Sender)
+	responses := make([]string, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, generateResponse(event, p))
 	}
+	bot.reply(msg, strings.Join(responses, " | ")+partialNote(err))
 }
 
 func (bot *Bot) navySeal(nick chat.Chatter) {
@@ -305,8 +532,20 @@ func fmtDuration(d time.Duration) string {
 	return response
 }
 
-func generateResponse(event *googlecal.Event) string {
-	diff := time.Until(calendar.StartTime(event))
+func generateResponse(event *googlecal.Event, p prefs) string {
+	span := calendar.EventSpan(event)
+	if span.AllDay {
+		return allDayResponse(event.Summary, span, time.Now())
+	}
+	if p.absolute() {
+		return fmt.Sprintf("%v (%v)", relativeResponse(event, span), p.formatTime(span.Start))
+	}
+
+	return relativeResponse(event, span)
+}
+
+func relativeResponse(event *googlecal.Event, span calendar.Span) string {
+	diff := time.Until(span.Start)
 	var response string
 	if diff.Round(time.Minute).Minutes() == 0 {
 		response = fmt.Sprintf("%v is starting now", event.Summary)
@@ -316,23 +555,94 @@ func generateResponse(event *googlecal.Event) string {
 	} else {
 		response = fmt.Sprintf("%v is in %v", event.Summary, fmtDuration(diff))
 	}
+	return strings.TrimSpace(response)
+}
+
+// remainingResponse describes how much time is left of an ongoing event
+func remainingResponse(event *googlecal.Event, now time.Time) string {
+	left := calendar.EndTime(event).Sub(now)
+	if left.Round(time.Minute) <= 0 {
+		return fmt.Sprintf("%v is ending now", event.Summary)
+	}
+
+	return fmt.Sprintf("%v ends in %v", event.Summary, strings.TrimSpace(fmtDuration(left)))
+}
+
+// allDayResponse describes all-day events in days, as seen from the event's own timezone
+func allDayResponse(title string, span calendar.Span, now time.Time) string {
+	now = now.In(span.Start.Location())
+	if span.Ongoing(now) {
+		if !span.MultiDay() {
+			return fmt.Sprintf("%v is today", title)
+		}
+		if calendar.DaysBetween(now, span.LastDay()) == 0 {
+			return fmt.Sprintf("%v ends today", title)
+		}
+		return fmt.Sprintf("%v runs through %v", title, fmtDay(now, span.LastDay()))
+	}
+
+	var response string
+	switch days := calendar.DaysBetween(now, span.Start); {
+	case days < 0:
+		return fmt.Sprintf("%v ended %v", title, fmtDay(now, span.LastDay()))
+	case days == 1:
+		response = fmt.Sprintf("%v is tomorrow", title)
+	default:
+		response = fmt.Sprintf("%v is in %d days", title, days)
+	}
+	if span.MultiDay() {
+		response += fmt.Sprintf(" and runs through %v", fmtDay(now, span.LastDay()))
+	}
+
 	return response
 }
 
+// fmtDay names a day by its weekday if it is within the next week
+func fmtDay(now, day time.Time) string {
+	if days := calendar.DaysBetween(now, day); days >= 0 && days < 7 {
+		return day.Weekday().String()
+	}
+
+	return day.Format("Jan 2")
+}
+
+// reply answers in private if the message was private or public chat is busy, and in public chat otherwise
+func (b *Bot) reply(msg chat.Message, resp string) {
+	if msg.Private || !b.publicAllowed("reply", b.rateLimits().Public) {
+		b.SendPriv(msg.Sender, resp)
+	} else {
+		b.Send(resp)
+	}
+}
+
+// idk alternates the emote of public "idk" replies
+func (b *Bot) idk() string {
+	b.stateLock.Lock()
+	defer b.stateLock.Unlock()
+
+	b.emote = !b.emote
+	if b.emote {
+		return "idk SHRUG"
+	}
+	return "idk TANTIES"
+}
+
 func (b *Bot) SendPriv(recipient chat.Chatter, msg string) {
-	err := b.chat.SendPriv(recipient, msg)
+	err := b.out.SendPriv(recipient, msg)
 	if err != nil {
 		logrus.Error("failed to send msg", err)
 	}
 }
 
 func (b *Bot) Send(msg string) {
-	// TODO: locking
+	b.stateLock.Lock()
 	if b.lastMsg == msg {
 		msg += " TANTIES"
 	}
 	b.lastMsg = msg
-	err := b.chat.Send(msg)
+	b.stateLock.Unlock()
+
+	err := b.out.Send(msg)
 	if err != nil {
 		logrus.Error("failed to send msg", err)
 	}
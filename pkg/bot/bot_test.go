@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/store"
+)
+
+// recorder collects the messages the bot sends, by recipient
+type recorder struct {
+	sync.Mutex
+	public []string
+	priv   map[chat.Chatter][]string
+}
+
+func (r *recorder) Send(message string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.public = append(r.public, message)
+	return nil
+}
+
+func (r *recorder) SendPriv(recipient chat.Chatter, message string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.priv[recipient] = append(r.priv[recipient], message)
+	return nil
+}
+
+func newTestBot(t *testing.T) (*Bot, *recorder) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &recorder{priv: make(map[chat.Chatter][]string)}
+	var cfg Config
+	cfg.RateLimit.Sender.Count = -1
+	cfg.RateLimit.Public.Count = -1
+
+	return &Bot{
+		chat:             &chat.Chat{},
+		out:              out,
+		store:            st,
+		cfg:              cfg,
+		name:             "whenis",
+		ongoingAdditions: make(map[chat.Chatter]*wizard),
+		limits:           newLimiter(),
+		choices:          newChoices(),
+		sessions:         newSessions(),
+	}, out
+}
@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	googlecal "google.golang.org/api/calendar/v3"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/search"
+)
+
+const (
+	// maxChoices limits the amount of event series offered when a query is ambiguous
+	maxChoices = 5
+	// choiceWindow is how long a chatter can pick one of the offered events
+	choiceWindow = time.Minute * 2
+)
+
+// choices are the events offered to chatters whose queries matched several event series
+type choices struct {
+	sync.Mutex
+	offers map[chat.Chatter]*offer
+}
+
+type offer struct {
+	events  []*googlecal.Event
+	expires time.Time
+}
+
+func newChoices() *choices {
+	return &choices{offers: make(map[chat.Chatter]*offer)}
+}
+
+// distinctSeries returns the first event of every series. Occurrences of a repeating event
+// belong to the same series, as do single events with the same title in the same calendar.
+func (bot *Bot) distinctSeries(events []*googlecal.Event) []*googlecal.Event {
+	seen := make(map[string]bool)
+	var series []*googlecal.Event
+	for _, e := range events {
+		key := "title:" + search.Fold(strings.TrimSpace(e.Summary))
+		if e.RecurringEventId != "" {
+			key = "series:" + e.RecurringEventId
+		}
+		key = bot.cal.CalendarOf(e) + "/" + key
+		if !seen[key] {
+			seen[key] = true
+			series = append(series, e)
+		}
+	}
+
+	return series
+}
+
+// offerChoices replies with a numbered list of events and waits for the sender to pick one
+func (bot *Bot) offerChoices(msg chat.Message, events []*googlecal.Event) {
+	bot.choices.Lock()
+	bot.choices.offers[msg.Sender] = &offer{
+		events:  events,
+		expires: time.Now().Add(choiceWindow),
+	}
+	bot.choices.Unlock()
+
+	entries := make([]string, 0, len(events))
+	for i, e := range events {
+		entries = append(entries, fmt.Sprintf("%d) %s (%s)", i+1, e.Summary, bot.cal.CalendarName(bot.cal.CalendarOf(e))))
+	}
+	bot.replyLong(msg, fmt.Sprintf("which one? %s | answer with the number", strings.Join(entries, " | ")))
+}
+
+// takeChoice answers a message that picks one of the events offered to its sender,
+// it returns false if the message is no pick. Any other message drops the offer, so
+// numbers sent later are not mistaken for picks.
+func (bot *Bot) takeChoice(msg chat.Message) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(msg.WithoutNick(bot.name)))
+	if err != nil {
+		bot.choices.Lock()
+		delete(bot.choices.offers, msg.Sender)
+		bot.choices.Unlock()
+		return false
+	}
+
+	bot.choices.Lock()
+	o, ok := bot.choices.offers[msg.Sender]
+	if ok && time.Now().After(o.expires) {
+		delete(bot.choices.offers, msg.Sender)
+		ok = false
+	}
+	if ok && n >= 1 && n <= len(o.events) {
+		delete(bot.choices.offers, msg.Sender)
+	}
+	bot.choices.Unlock()
+	if !ok {
+		return false
+	}
+
+	if n < 1 || n > len(o.events) {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("pick a number from 1 to %d", len(o.events)))
+		return true
+	}
+	bot.reply(msg, generateResponse(o.events[n-1], bot.prefs(msg.Sender)))
+
+	return true
+}
+
+// expireChoices forgets offers that were not picked in time
+func (bot *Bot) expireChoices(now time.Time) {
+	bot.choices.Lock()
+	defer bot.choices.Unlock()
+
+	for c, o := range bot.choices.offers {
+		if now.After(o.expires) {
+			delete(bot.choices.offers, c)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	googlecal "google.golang.org/api/calendar/v3"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+)
+
+func TestDistinctSeries(t *testing.T) {
+	bot, _ := newTestBot(t)
+	bot.cal = &calendar.Calendar{}
+
+	practice := &googlecal.Event{Id: "p1", Summary: "Practice", RecurringEventId: "practice"}
+	practice2 := &googlecal.Event{Id: "p2", Summary: "Practice (moved)", RecurringEventId: "practice"}
+	race := &googlecal.Event{Id: "r1", Summary: "Race", RecurringEventId: "race"}
+	// single events fall back to their title
+	special := &googlecal.Event{Id: "s1", Summary: "Café Stream"}
+	special2 := &googlecal.Event{Id: "s2", Summary: " cafe stream"}
+	other := &googlecal.Event{Id: "o1", Summary: "Other Stream"}
+
+	tests := []struct {
+		name   string
+		events []*googlecal.Event
+		want   []*googlecal.Event
+	}{
+		{"empty", nil, nil},
+		{"one series", []*googlecal.Event{practice, practice2}, []*googlecal.Event{practice}},
+		{"two series", []*googlecal.Event{practice, race, practice2}, []*googlecal.Event{practice, race}},
+		{"same title", []*googlecal.Event{special, special2}, []*googlecal.Event{special}},
+		{"other title", []*googlecal.Event{special, other}, []*googlecal.Event{special, other}},
+	}
+	for _, tt := range tests {
+		if got := bot.distinctSeries(tt.events); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: distinctSeries() = %v, want %v", tt.name, ids(got), ids(tt.want))
+		}
+	}
+}
+
+func ids(events []*googlecal.Event) []string {
+	res := make([]string, 0, len(events))
+	for _, e := range events {
+		res = append(res, e.Id)
+	}
+
+	return res
+}
@@ -0,0 +1,141 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/MemeLabs/whenis/pkg/util"
+)
+
+// Config holds the settings of the bot's unprompted behaviour
+type Config struct {
+	Announce   AnnounceConfig   `json:"announce"`
+	Digest     DigestConfig     `json:"digest"`
+	Moderation ModerationConfig `json:"moderation"`
+	RateLimit  RateLimitConfig  `json:"rateLimit"`
+}
+
+// RateLimitConfig limits how often chatters can use the bot. Counts of 0 use the defaults,
+// negative counts turn a limit off.
+type RateLimitConfig struct {
+	// Sender limits the requests of each chatter, 10 per minute by default
+	Sender Limit `json:"sender"`
+	// Commands limits single commands of each chatter, e.g. {"add": {"count": 2, "per": "10m"}},
+	// plain event queries are called "query"
+	Commands map[string]Limit `json:"commands"`
+	// Public limits the replies in public chat, further replies are sent as private messages,
+	// 6 per minute by default
+	Public Limit `json:"public"`
+	// Idk limits the public replies to queries without result, 1 per minute by default
+	Idk Limit `json:"idk"`
+	// Strikes is how often a chatter may run into a limit within the cooldown before
+	// being ignored for the cooldown, 3 and 5m by default
+	Strikes  int           `json:"strikes"`
+	Cooldown util.Duration `json:"cooldown"`
+}
+
+// Limit allows Count hits per time window
+type Limit struct {
+	Count int           `json:"count"`
+	Per   util.Duration `json:"per"`
+}
+
+func (l Limit) String() string {
+	if l.Count <= 0 {
+		return "unlimited"
+	}
+
+	return fmt.Sprintf("%d per %s", l.Count, fmtShortDuration(time.Duration(l.Per)))
+}
+
+// ModerationConfig limits who can add events and how many. Mods are exempt.
+type ModerationConfig struct {
+	// AllowlistOnly only lets chatters on the allow list add events
+	AllowlistOnly bool `json:"allowlistOnly"`
+	// MaxPerDay limits the events a chatter can add within 24 hours, 0 means no limit
+	MaxPerDay int `json:"maxPerDay"`
+	// MaxUpcoming limits the events of a chatter that did not end yet, 0 means no limit
+	MaxUpcoming int `json:"maxUpcoming"`
+	// Approval queues events added by chatters until a mod approves them
+	Approval bool `json:"approval"`
+	// PendingTTL is how long queued events wait for approval, 48h if unset
+	PendingTTL util.Duration `json:"pendingTtl"`
+}
+
+// AnnounceConfig controls public announcements of starting events
+type AnnounceConfig struct {
+	// Calendars whose events are announced, announcements are off if it is empty
+	Calendars []string `json:"calendars"`
+	// LeadTimes are announced ahead of the start, e.g. ["30m", "10m", "1m"]
+	LeadTimes []util.Duration `json:"leadTimes"`
+	// MinInterval is the least time between two announcements
+	MinInterval util.Duration `json:"minInterval"`
+	// MaxPerHour limits the amount of announcements per hour, 0 means no limit
+	MaxPerHour int `json:"maxPerHour"`
+	// QuietHours suppresses announcements during part of the day
+	QuietHours *QuietHours `json:"quietHours"`
+}
+
+// DigestConfig controls the scheduled schedule posts
+type DigestConfig struct {
+	// Daily is the time of day of the daily digest, e.g. "09:00", it is off if empty
+	Daily string `json:"daily"`
+	// Weekly is the time of day of the weekly overview posted on mondays, it is off if empty
+	Weekly string `json:"weekly"`
+	// Timezone of the digests, the default timezone if empty
+	Timezone string `json:"timezone"`
+	// Template and WeeklyTemplate replace the default text/template formats
+	Template       string `json:"template"`
+	WeeklyTemplate string `json:"weeklyTemplate"`
+	// MaxLength is the length at which digests are split into several messages
+	MaxLength int `json:"maxLength"`
+}
+
+// QuietHours is a daily time range, e.g. from "01:00" to "08:00". Ranges may wrap around midnight.
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
+
+// Contains returns true if t falls within the quiet hours
+func (q *QuietHours) Contains(t time.Time) (bool, error) {
+	if q == nil {
+		return false, nil
+	}
+
+	loc := calendar.DefaultLocation
+	if q.Timezone != "" {
+		var err error
+		if loc, err = timeparse.ParseZone(q.Timezone); err != nil {
+			return false, err
+		}
+	}
+	start, err := minuteOfDay(q.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := minuteOfDay(q.End)
+	if err != nil {
+		return false, err
+	}
+
+	t = t.In(loc)
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return now >= start && now < end, nil
+	}
+
+	return now >= start || now < end, nil
+}
+
+func minuteOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, use the format 15:04", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	digestBucket = "digest"
+	// digestGrace is how late a digest may still be posted, e.g. after a restart
+	digestGrace = time.Hour
+	// defaultMaxLength is the length at which public messages are split
+	defaultMaxLength = 500
+	// digestSeparator separates events in the default templates, long digests are split there
+	digestSeparator = " | "
+)
+
+const (
+	defaultDailyTemplate = `today on the calendar: {{if not .Events}}nothing PepeHands{{end}}` +
+		`{{range $i, $e := .Events}}{{if $i}} | {{end}}{{if $e.AllDay}}all day{{else}}{{$e.Time}}{{end}} {{$e.Title}}{{end}}`
+	defaultWeeklyTemplate = `this week on the calendar: {{if not .Events}}nothing PepeHands{{end}}` +
+		`{{range $i, $e := .Events}}{{if $i}} | {{end}}{{$e.Day}} {{if not $e.AllDay}}{{$e.Time}} {{end}}{{$e.Title}}{{end}}`
+)
+
+// digestData is passed to the digest templates
+type digestData struct {
+	Date   string
+	Events []digestEvent
+}
+
+type digestEvent struct {
+	Title    string
+	Calendar string
+	Day      string
+	Time     string
+	// In is how long until the event starts, e.g. "3 hours and 5 minutes"
+	In     string
+	AllDay bool
+}
+
+// postDigests posts the daily and weekly digests once their time has come
+func (bot *Bot) postDigests(ctx context.Context, now time.Time) {
+	cfg := bot.cfg.Digest
+	if cfg.Daily == "" && cfg.Weekly == "" {
+		return
+	}
+
+	loc := calendar.DefaultLocation
+	if cfg.Timezone != "" {
+		var err error
+		if loc, err = timeparse.ParseZone(cfg.Timezone); err != nil {
+			logrus.Error("invalid digest timezone", err)
+			return
+		}
+	}
+	local := now.In(loc)
+	today := calendar.Midnight(local)
+
+	if cfg.Daily != "" {
+		bot.postDigest(ctx, now, "daily", cfg.Daily, today, today.AddDate(0, 0, 1), cfg.Template, defaultDailyTemplate)
+	}
+	if cfg.Weekly != "" && local.Weekday() == time.Monday {
+		bot.postDigest(ctx, now, "weekly", cfg.Weekly, today, today.AddDate(0, 0, 7), cfg.WeeklyTemplate, defaultWeeklyTemplate)
+	}
+}
+
+func (bot *Bot) postDigest(ctx context.Context, now time.Time, kind, at string, from, to time.Time, tmpl, fallback string) {
+	minute, err := minuteOfDay(at)
+	if err != nil {
+		logrus.Error("invalid digest time", err)
+		return
+	}
+	scheduled := from.Add(time.Duration(minute) * time.Minute)
+	if now.Before(scheduled) || now.Sub(scheduled) > digestGrace {
+		return
+	}
+
+	date := from.Format("2006-01-02")
+	var last string
+	if _, err := bot.store.Get(digestBucket, kind, &last); err != nil {
+		logrus.Error("failed to load digest state", err)
+		return
+	}
+	if last == date {
+		return
+	}
+
+	events, err := bot.cal.Between(ctx, "", from, to)
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to fetch digest events", err)
+		return
+	}
+
+	data := digestData{Date: date}
+	for _, event := range events {
+		span := calendar.EventSpan(event)
+		// all-day events are reported in their own timezone, the rest in the digest's
+		start := span.Start
+		if !span.AllDay {
+			start = start.In(from.Location())
+		}
+		data.Events = append(data.Events, digestEvent{
+			Title:    event.Summary,
+			Calendar: bot.cal.CalendarName(bot.cal.CalendarOf(event)),
+			Day:      start.Format("Mon"),
+			Time:     start.Format("15:04"),
+			In:       strings.TrimSpace(fmtDuration(time.Until(span.Start))),
+			AllDay:   span.AllDay,
+		})
+	}
+
+	if tmpl == "" {
+		tmpl = fallback
+	}
+	t, err := template.New(kind).Parse(tmpl)
+	if err != nil {
+		logrus.Error("invalid digest template", err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		logrus.Error("failed to render digest", err)
+		return
+	}
+
+	// store first, posting twice is worse than missing a digest
+	if err := bot.store.Put(digestBucket, kind, date); err != nil {
+		logrus.Error("failed to save digest state", err)
+		return
+	}
+
+	maxLength := bot.cfg.Digest.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxLength
+	}
+	for _, part := range splitMessage(buf.String(), maxLength, digestSeparator) {
+		bot.Send(part)
+	}
+	logrus.WithFields(logrus.Fields{
+		"kind":   kind,
+		"events": len(events),
+	}).Info("posted digest")
+}
+
+// splitMessage splits text into parts of at most max bytes, preferably at sep and otherwise at spaces
+func splitMessage(text string, max int, sep string) []string {
+	var parts []string
+	for len(text) > max {
+		// a separator or space right after max still ends a part that fits
+		window := text
+		if len(window) > max+len(sep) {
+			window = window[:max+len(sep)]
+		}
+		cut := strings.LastIndex(window, sep)
+		next := cut + len(sep)
+		if cut <= 0 {
+			cut = strings.LastIndex(text[:max+1], " ")
+			next = cut + 1
+		}
+		if cut <= 0 {
+			cut = max
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			next = cut
+		}
+		parts = append(parts, strings.TrimSpace(text[:cut]))
+		text = text[next:]
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		parts = append(parts, text)
+	}
+
+	return parts
+}
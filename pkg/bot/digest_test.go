@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want []string
+	}{
+		{"empty", "", 10, nil},
+		{"blank", "   ", 10, nil},
+		{"short", "a | b", 10, []string{"a | b"}},
+		{"exactly max", "aaa | bbb", 9, []string{"aaa | bbb"}},
+		{"one over max", "aaa | bbbb", 9, []string{"aaa", "bbbb"}},
+		// the separator is dropped at the cut and kept within parts
+		{"at separators", "aaa | bbb | ccc | ddd | eee", 12, []string{"aaa | bbb", "ccc | ddd", "eee"}},
+		// parts end right before a separator or space that starts at max
+		{"separator after max", "aaa | bbb | ccc", 9, []string{"aaa | bbb", "ccc"}},
+		{"space after max", "aaaa bbbb cccc", 9, []string{"aaaa bbbb", "cccc"}},
+		{"separator at the start", " | aaaa bbbb", 9, []string{"| aaaa", "bbbb"}},
+		// entries longer than max are cut at spaces, or anywhere if they have none
+		{"long entry", "aaaa bbbb cccc dddd | e", 12, []string{"aaaa bbbb", "cccc dddd", "e"}},
+		{"long word", "aaaaaaaaaaaa | b", 5, []string{"aaaaa", "aaaaa", "aa", "b"}},
+		{"runes", "ääää", 5, []string{"ää", "ää"}},
+	}
+	for _, tt := range tests {
+		got := splitMessage(tt.text, tt.max, " | ")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitMessage(%q, %d) = %q, want %q", tt.name, tt.text, tt.max, got, tt.want)
+		}
+		for _, part := range got {
+			if len(part) > tt.max {
+				t.Errorf("%s: part %q is longer than %d", tt.name, part, tt.max)
+			}
+		}
+	}
+}
+
+func TestSplitMessageKeepsText(t *testing.T) {
+	entries := make([]string, 40)
+	for i := range entries {
+		entries[i] = strings.Repeat("x", i%7+1) + " event"
+	}
+	text := strings.Join(entries, " | ")
+
+	parts := splitMessage(text, 50, " | ")
+	if got := strings.Join(parts, " | "); got != text {
+		t.Errorf("joined parts = %q, want %q", got, text)
+	}
+}
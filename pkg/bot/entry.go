@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// eventEntry is an event that is being added. The wizard and the one-shot form fill it
+// through the same setters, so both follow the same rules.
+type eventEntry struct {
+	calendar       string
+	title          string
+	searchKeywords string
+	time           time.Time
+	duration       time.Duration
+	recurrence     *timeparse.Recurrence
+}
+
+var errPast = errors.New("thats in the past FeelsPepoMan")
+
+func (e *eventEntry) setTitle(s string) error {
+	s = strings.TrimSpace(s)
+	if err := validateTitle(s); err != nil {
+		return err
+	}
+	e.title = s
+
+	return nil
+}
+
+func (e *eventEntry) setKeywords(s string) {
+	e.searchKeywords = strings.TrimSpace(s)
+	if e.searchKeywords == "" {
+		e.searchKeywords = "-"
+	}
+}
+
+func (e *eventEntry) setStart(s string, now time.Time, loc *time.Location) error {
+	start, err := timeparse.Parse(s, now, loc)
+	if err != nil {
+		return fmt.Errorf("I could not understand the start (%v)", err)
+	}
+	if start.Before(now) {
+		return errPast
+	}
+	e.time = start
+
+	return nil
+}
+
+func (e *eventEntry) setDuration(s string) error {
+	d, err := timeparse.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return errors.New("I could not understand the duration, provide it in the format `2h5m`")
+	}
+	e.duration = d
+
+	return nil
+}
+
+// setRecurrence reads whether and how the event repeats, the start has to be set before
+func (e *eventEntry) setRecurrence(s string, now time.Time) error {
+	// rules are read and expanded in the timezone the start was given in
+	r, err := timeparse.ParseRecurrence(s, now, e.time.Location())
+	if err == nil && r != nil {
+		err = r.Matches(e.time)
+	}
+	if err != nil {
+		return fmt.Errorf("I could not understand the repetition (%v)", err)
+	}
+	e.recurrence = r
+
+	return nil
+}
+
+// summary describes the entry in the chatter's timezone
+func (e *eventEntry) summary(p prefs) string {
+	s := fmt.Sprintf("%s, %s for %s", e.title, p.formatTime(e.time), fmtShortDuration(e.duration))
+	if e.recurrence != nil {
+		s += ", " + e.recurrence.String()
+	}
+	if e.searchKeywords != "-" {
+		s += ", keywords " + e.searchKeywords
+	}
+
+	return s
+}
+
+// finishEvent adds a complete entry to the calendar, or queues it if it needs a mod's approval
+func (bot *Bot) finishEvent(ctx context.Context, msg chat.Message, e *eventEntry) {
+	if err := bot.mayCreate(msg); err != nil {
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+	if bot.needsApproval(msg) {
+		bot.submitEvent(msg, e)
+		return
+	}
+	id, err := bot.createEvent(ctx, msg.Sender, e)
+	if err != nil {
+		logrus.Error("failed to add event", err)
+		bot.SendPriv(msg.Sender, fmt.Sprintf("could not add event %v", err))
+		return
+	}
+	bot.SendPriv(msg.Sender, fmt.Sprintf("noted PepoG you can change it with `-edit %s` or `-delete %s`", id, id))
+}
+
+// oneShotKeys start the parts of the one-shot form, the title comes first
+var oneShotKeys = map[string]bool{"at": true, "for": true, "keywords": true, "repeats": true, "cal": true}
+
+const oneShotUsage = "use `-add \"Movie Night\" at \"sat 20:00 UTC\" for 2h keywords \"movie,watchparty\"`, " +
+	"optionally followed by `repeats \"every sat\"` and `cal \"name\"`, or just `-add` to be asked step by step"
+
+// parseOneShot splits the arguments of the one-shot form into its parts. Values are
+// single words, quoted, or run up to the next key.
+func parseOneShot(args []string) (map[string]string, error) {
+	args = util.SplitArgs(strings.Join(args, " "))
+	parts := make(map[string]string)
+	key := "title"
+	var value []string
+	flush := func() error {
+		if _, ok := parts[key]; ok {
+			return fmt.Errorf("%s is given twice, put values that contain it in quotes", key)
+		}
+		parts[key] = strings.Join(value, " ")
+		value = nil
+		return nil
+	}
+	for _, arg := range args {
+		if oneShotKeys[strings.ToLower(arg)] && (key != "title" || len(value) > 0) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			key = strings.ToLower(arg)
+			continue
+		}
+		value = append(value, arg)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+// addOneShot adds an event described in a single message, all parts are checked at once
+func (bot *Bot) addOneShot(ctx context.Context, msg chat.Message, args []string, calOverride string) {
+	parts, err := parseOneShot(args)
+	if err != nil {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("%v, %s", err, oneShotUsage))
+		return
+	}
+	if parts["at"] == "" || parts["for"] == "" {
+		bot.SendPriv(msg.Sender, oneShotUsage)
+		return
+	}
+	if ref := parts["cal"]; ref != "" {
+		calOverride = ref
+	}
+
+	now := time.Now()
+	p := bot.prefs(msg.Sender)
+	e := &eventEntry{}
+	var problems []string
+	if err := e.setTitle(parts["title"]); err != nil {
+		problems = append(problems, err.Error())
+	}
+	e.setKeywords(parts["keywords"])
+	if err := e.setStart(parts["at"], now, p.location()); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if err := e.setDuration(parts["for"]); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if r := parts["repeats"]; r != "" && !e.time.IsZero() {
+		if err := e.setRecurrence(r, now); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	target, err := bot.cal.Target(ctx, calOverride, string(msg.Sender))
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		bot.SendPriv(msg.Sender, strings.Join(problems, " | "))
+		return
+	}
+	e.calendar = target
+
+	logrus.WithFields(logrus.Fields{
+		"chatter":  msg.Sender,
+		"calendar": target,
+		"title":    e.title,
+	}).Info("adding event in one message")
+	bot.SendPriv(msg.Sender, e.summary(p))
+	bot.finishEvent(ctx, msg, e)
+}
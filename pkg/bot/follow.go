@@ -0,0 +1,204 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	subscriptionsBucket = "subscriptions"
+	// maxSubscriptions limits the amount of things a single chatter can follow
+	maxSubscriptions = 20
+	// syncInterval is how often calendars are checked for new, moved and cancelled events
+	syncInterval = time.Minute * 5
+	// maxUpdates limits the amount of changes listed in a single notification
+	maxUpdates = 5
+)
+
+// subscription follows either a keyword or a whole calendar
+type subscription struct {
+	ID         string       `json:"id"`
+	Chatter    chat.Chatter `json:"chatter"`
+	Keyword    string       `json:"keyword,omitempty"`
+	CalendarID string       `json:"calendarId,omitempty"`
+	// Name is what the chatter followed, for listing
+	Name string `json:"name"`
+}
+
+func (s subscription) matches(c calendar.Change, calName string) bool {
+	if s.CalendarID != "" {
+		return s.CalendarID == c.CalendarID
+	}
+
+	return util.ContainsFold(c.Event.Summary, s.Keyword) ||
+		util.ContainsFold(c.Event.Description, s.Keyword) ||
+		util.ContainsFold(calName, s.Keyword)
+}
+
+func (bot *Bot) follow(ctx context.Context, msg chat.Message, keyword, calRef string) {
+	if len(bot.subscriptionsOf(msg.Sender)) >= maxSubscriptions {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("you can't follow more than %d things, unfollow some with `-unfollow`", maxSubscriptions))
+		return
+	}
+
+	s := subscription{
+		ID:      util.ShortID(),
+		Chatter: msg.Sender,
+	}
+	switch {
+	case calRef != "":
+		id, err := bot.cal.ResolveCalendar(ctx, calRef)
+		if err != nil {
+			bot.SendPriv(msg.Sender, err.Error())
+			return
+		}
+		s.CalendarID = id
+		s.Name = bot.cal.CalendarName(id)
+	case keyword != "":
+		s.Keyword = keyword
+		s.Name = keyword
+	default:
+		bot.SendPriv(msg.Sender, "tell me what to follow, e.g. `-follow Formula 1` or `-follow -cal f1`")
+		return
+	}
+
+	if err := bot.store.Put(subscriptionsBucket, s.ID, s); err != nil {
+		logrus.Error("failed to save subscription", err)
+		bot.SendPriv(msg.Sender, "could not save your subscription")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter":  msg.Sender,
+		"keyword":  s.Keyword,
+		"calendar": s.CalendarID,
+	}).Info("added subscription")
+
+	bot.SendPriv(msg.Sender, fmt.Sprintf("I'll let you know when %s events are added, moved or cancelled, id `%s`", s.Name, s.ID))
+}
+
+// subscriptionsOf returns everything a chatter follows, or all subscriptions if c is empty
+func (bot *Bot) subscriptionsOf(c chat.Chatter) []subscription {
+	var subs []subscription
+	err := bot.store.ForEach(subscriptionsBucket, func(_ string, raw json.RawMessage) error {
+		var s subscription
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		if c == "" || strings.EqualFold(string(s.Chatter), string(c)) {
+			subs = append(subs, s)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to load subscriptions", err)
+	}
+
+	return subs
+}
+
+func (bot *Bot) listSubscriptions(msg chat.Message) {
+	subs := bot.subscriptionsOf(msg.Sender)
+	if len(subs) == 0 {
+		bot.SendPriv(msg.Sender, "you don't follow anything, try `-follow Formula 1`")
+		return
+	}
+
+	lines := make([]string, 0, len(subs))
+	for _, s := range subs {
+		kind := "keyword"
+		if s.CalendarID != "" {
+			kind = "calendar"
+		}
+		lines = append(lines, fmt.Sprintf("`%s` %s %s", s.ID, kind, s.Name))
+	}
+	bot.SendPriv(msg.Sender, strings.Join(lines, " | "))
+}
+
+func (bot *Bot) unfollow(msg chat.Message, id string) {
+	var removed int
+	for _, s := range bot.subscriptionsOf(msg.Sender) {
+		if id != "all" && s.ID != id {
+			continue
+		}
+		if err := bot.store.Delete(subscriptionsBucket, s.ID); err != nil {
+			logrus.Error("failed to delete subscription", err)
+			continue
+		}
+		removed++
+	}
+
+	if removed == 0 {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("you don't follow `%s`, see `-following`", id))
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"id":      id,
+	}).Info("removed subscriptions")
+	bot.SendPriv(msg.Sender, "PepOk")
+}
+
+// syncEvents checks the calendars for changes and tells followers about them
+func (bot *Bot) syncEvents(ctx context.Context, now time.Time) {
+	if now.Sub(bot.lastSync) < syncInterval {
+		return
+	}
+
+	changes, err := bot.cal.Sync(ctx)
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to sync calendars", err)
+		return
+	}
+	bot.lastSync = now
+	if len(changes) == 0 {
+		return
+	}
+	logrus.WithField("changes", len(changes)).Info("synced calendars")
+
+	subs := bot.subscriptionsOf("")
+	updates := make(map[chat.Chatter][]string)
+	var order []chat.Chatter
+	for _, c := range changes {
+		calName := bot.cal.CalendarName(c.CalendarID)
+		notified := make(map[string]bool)
+		for _, s := range subs {
+			key := strings.ToLower(string(s.Chatter))
+			if notified[key] || !s.matches(c, calName) {
+				continue
+			}
+			notified[key] = true
+			if _, ok := updates[s.Chatter]; !ok {
+				order = append(order, s.Chatter)
+			}
+			updates[s.Chatter] = append(updates[s.Chatter], describeChange(c, bot.prefs(s.Chatter)))
+		}
+	}
+
+	for _, chatter := range order {
+		lines := updates[chatter]
+		if len(lines) > maxUpdates {
+			lines = append(lines[:maxUpdates], fmt.Sprintf("and %d more", len(lines)-maxUpdates))
+		}
+		bot.SendPriv(chatter, strings.Join(lines, " | "))
+	}
+}
+
+func describeChange(c calendar.Change, p prefs) string {
+	switch c.Kind {
+	case calendar.EventMoved:
+		return fmt.Sprintf("moved: %s to %s (was %s)", c.Event.Summary,
+			p.formatTime(calendar.StartTime(c.Event)), p.formatTime(calendar.StartTime(c.Previous)))
+	case calendar.EventCancelled:
+		return fmt.Sprintf("cancelled: %s (%s)", c.Event.Summary, p.formatTime(calendar.StartTime(c.Event)))
+	default:
+		return fmt.Sprintf("new: %s (%s)", c.Event.Summary, p.formatTime(calendar.StartTime(c.Event)))
+	}
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	googlecal "google.golang.org/api/calendar/v3"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/sirupsen/logrus"
+)
+
+// maxLastWindow limits the range -last counts occurrences in
+const maxLastWindow = time.Hour * 24 * 365
+
+// lastQuery answers when an event last took place, e.g. "-last Formula 1". With a
+// leading window, e.g. "-last 30d Formula 1", it also counts the occurrences within it.
+func (bot *Bot) lastQuery(ctx context.Context, msg chat.Message, args []string) {
+	var window time.Duration
+	if len(args) > 1 {
+		if d, err := timeparse.ParseDuration(args[0]); err == nil && d > 0 {
+			window = d
+			args = args[1:]
+		}
+	}
+	if window > maxLastWindow {
+		window = maxLastWindow
+	}
+	query := bot.resolveAliases(strings.Join(args, " "))
+	if strings.TrimSpace(query) == "" {
+		bot.SendPriv(msg.Sender, "tell me what to look for, e.g. `-last Formula 1` or `-last 30d Formula 1`")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"query":   query,
+		"window":  window,
+	}).Info("got a last request")
+
+	var last *googlecal.Event
+	var count int
+	var err error
+	if window > 0 {
+		var events []*googlecal.Event
+		events, err = bot.cal.Past(ctx, query, window)
+		count = len(events)
+		if count > 0 {
+			last = events[count-1]
+		}
+	} else {
+		last, err = bot.cal.Last(ctx, query)
+	}
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to handle request", err)
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+
+	if last == nil {
+		if window > 0 {
+			bot.reply(msg, fmt.Sprintf("nothing like that in the last %s%s", fmtPastWindow(window), partialNote(err)))
+		} else {
+			bot.reply(msg, "nothing like that in the last year"+partialNote(err))
+		}
+		return
+	}
+
+	resp := pastResponse(last, bot.prefs(msg.Sender), time.Now())
+	if window > 0 {
+		times := "times"
+		if count == 1 {
+			times = "time"
+		}
+		resp += fmt.Sprintf(", %d %s in the last %s", count, times, fmtPastWindow(window))
+	}
+	bot.reply(msg, resp+partialNote(err))
+}
+
+// pastResponse describes how long ago an event took place, e.g. "Formula 1 Race was 6 days ago"
+func pastResponse(event *googlecal.Event, p prefs, now time.Time) string {
+	span := calendar.EventSpan(event)
+	if span.AllDay {
+		switch days := calendar.DaysBetween(now.In(span.Start.Location()), span.LastDay()); {
+		case days == 0:
+			return fmt.Sprintf("%v ended today", event.Summary)
+		case days == -1:
+			return fmt.Sprintf("%v was yesterday", event.Summary)
+		default:
+			return fmt.Sprintf("%v was %d days ago", event.Summary, -days)
+		}
+	}
+
+	ago := now.Sub(span.Start)
+	var resp string
+	if ago >= time.Hour*48 {
+		resp = fmt.Sprintf("%v was %d days ago", event.Summary, int(ago.Hours()/24))
+	} else {
+		resp = fmt.Sprintf("%v was %v ago", event.Summary, strings.TrimSpace(fmtDuration(ago)))
+	}
+	if p.absolute() {
+		resp += fmt.Sprintf(" (%v)", p.formatTime(span.Start))
+	}
+
+	return resp
+}
+
+// fmtPastWindow formats a window like "30 days" or "12 hours"
+func fmtPastWindow(d time.Duration) string {
+	if d >= time.Hour*24 && d%(time.Hour*24) == 0 {
+		if d == time.Hour*24 {
+			return "day"
+		}
+		return fmt.Sprintf("%d days", d/(time.Hour*24))
+	}
+
+	return strings.TrimSpace(fmtDuration(d))
+}
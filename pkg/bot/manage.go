@@ -0,0 +1,394 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	googlecal "google.golang.org/api/calendar/v3"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	createdBucket = "created"
+	// maxMine limits the amount of events listed by -mine
+	maxMine = 10
+)
+
+// createdEvent records an event that was added through the bot
+type createdEvent struct {
+	ID         string       `json:"id"`
+	EventID    string       `json:"eventId"`
+	CalendarID string       `json:"calendarId"`
+	Creator    chat.Chatter `json:"creator"`
+	Title      string       `json:"title"`
+	Start      time.Time    `json:"start"`
+	End        time.Time    `json:"end"`
+	Created    time.Time    `json:"created"`
+	// Repeats describes the recurrence of repeating events, Until is the end of the last
+	// occurrence, or the end of the rule, if there is one
+	Repeats string    `json:"repeats,omitempty"`
+	Until   time.Time `json:"until,omitempty"`
+	// Rule is the recurrence the event was created with
+	Rule *timeparse.Recurrence `json:"rule,omitempty"`
+}
+
+// upcoming returns true if the event, or an occurrence of it, did not end yet
+func (rec createdEvent) upcoming(now time.Time) bool {
+	if rec.Repeats != "" {
+		return rec.Until.IsZero() || rec.Until.After(now)
+	}
+
+	return rec.End.After(now)
+}
+
+func validateTitle(title string) error {
+	if title == "" {
+		return errors.New("invalid title, please try again")
+	}
+	if strings.HasPrefix(title, "!") {
+		return errors.New("invalid title, titles must not begin with '!'")
+	}
+
+	return nil
+}
+
+// recordCreated remembers who created an event and returns the id it can be managed with
+func (bot *Bot) recordCreated(calID string, event *googlecal.Event, creator chat.Chatter, recurrence *timeparse.Recurrence) string {
+	rec := createdEvent{
+		ID:         util.ShortID(),
+		EventID:    event.Id,
+		CalendarID: calID,
+		Creator:    creator,
+		Title:      event.Summary,
+		Start:      calendar.StartTime(event),
+		End:        calendar.EndTime(event),
+		Created:    time.Now(),
+	}
+	if recurrence != nil {
+		rec.Repeats = recurrence.String()
+		rec.Rule = recurrence
+		rec.updateUntil()
+	}
+	if err := bot.store.Put(createdBucket, rec.ID, rec); err != nil {
+		logrus.Error("failed to record created event", err)
+	}
+	bot.logCreation(creator, rec.Created)
+
+	return rec.ID
+}
+
+// updateUntil sets the end of a repeating event from its rule
+func (rec *createdEvent) updateUntil() {
+	if rec.Rule == nil {
+		return
+	}
+	rec.Until = rec.Rule.Until
+	if rec.Rule.Count > 0 {
+		rec.Until = rec.Rule.LastStart(rec.Start).Add(rec.End.Sub(rec.Start))
+	}
+}
+
+// seriesZone returns the timezone the rule of a repeating event is expanded in
+func (bot *Bot) seriesZone(ctx context.Context, rec createdEvent) (string, error) {
+	series, err := bot.cal.Event(ctx, rec.CalendarID, rec.EventID)
+	if err != nil {
+		return "", err
+	}
+
+	return series.Start.TimeZone, nil
+}
+
+// createdBy returns the events created by a chatter sorted by start, or all of them if c is empty
+func (bot *Bot) createdBy(c chat.Chatter) []createdEvent {
+	var events []createdEvent
+	err := bot.store.ForEach(createdBucket, func(_ string, raw json.RawMessage) error {
+		var rec createdEvent
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return err
+		}
+		if c == "" || strings.EqualFold(string(rec.Creator), string(c)) {
+			events = append(events, rec)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to load created events", err)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+
+	return events
+}
+
+// managedEvent looks up a created event and checks that the sender may change it
+func (bot *Bot) managedEvent(msg chat.Message, id string) (createdEvent, bool) {
+	var rec createdEvent
+	ok, err := bot.store.Get(createdBucket, id, &rec)
+	if err != nil {
+		logrus.Error("failed to load created event", err)
+	}
+	if !ok {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("there is no event `%s`, see `-mine`", id))
+		return rec, false
+	}
+	if !strings.EqualFold(string(rec.Creator), string(msg.Sender)) && !bot.isMod(msg) {
+		bot.SendPriv(msg.Sender, "only the creator of an event or mods can change it")
+		return rec, false
+	}
+
+	return rec, true
+}
+
+func (bot *Bot) listMine(msg chat.Message) {
+	var upcoming []createdEvent
+	for _, rec := range bot.createdBy(msg.Sender) {
+		if rec.upcoming(time.Now()) {
+			upcoming = append(upcoming, rec)
+		}
+	}
+	if len(upcoming) == 0 {
+		bot.SendPriv(msg.Sender, "you have no upcoming events, add one with `-add`")
+		return
+	}
+
+	p := bot.prefs(msg.Sender)
+	lines := make([]string, 0, len(upcoming))
+	for i, rec := range upcoming {
+		if i == maxMine {
+			lines = append(lines, fmt.Sprintf("and %d more", len(upcoming)-maxMine))
+			break
+		}
+		if rec.Repeats != "" {
+			lines = append(lines, fmt.Sprintf("`%s` %s (%s, %s)", rec.ID, rec.Title, p.formatTime(rec.Start), rec.Repeats))
+		} else {
+			lines = append(lines, fmt.Sprintf("`%s` %s (%s)", rec.ID, rec.Title, p.formatTime(rec.Start)))
+		}
+	}
+	bot.SendPriv(msg.Sender, strings.Join(lines, " | "))
+}
+
+const editUsage = "use `-edit <id> title|keywords|start|duration <value>`, e.g. `-edit abc123 start friday 20:00 CET`, " +
+	"add `-on <day>` to change a single occurrence of a repeating event"
+
+// occurrence looks up the occurrence of a repeating event on the day described by on
+func (bot *Bot) occurrence(ctx context.Context, msg chat.Message, rec createdEvent, on string) (*googlecal.Event, bool) {
+	if rec.Repeats == "" {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("%s does not repeat, leave out `-on`", rec.Title))
+		return nil, false
+	}
+	day, err := timeparse.ParseDate(on, time.Now(), bot.prefs(msg.Sender).location())
+	if err != nil {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("I could not understand that (%v)", err))
+		return nil, false
+	}
+
+	event, err := bot.cal.Occurrence(ctx, rec.CalendarID, rec.EventID, day, day.AddDate(0, 0, 1))
+	if errors.Is(err, calendar.ErrEventNotFound) {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("%s does not take place on %s", rec.Title, day.Format("Mon Jan 2")))
+		return nil, false
+	}
+	if err != nil {
+		logrus.Error("failed to find occurrence", err)
+		bot.SendPriv(msg.Sender, err.Error())
+		return nil, false
+	}
+
+	return event, true
+}
+
+// splitOn separates a trailing `-on <day>` from arguments
+func splitOn(args []string) ([]string, string) {
+	for i, arg := range args {
+		if arg == "-on" || arg == "--on" {
+			return args[:i], strings.Join(args[i+1:], " ")
+		}
+	}
+
+	return args, ""
+}
+
+func (bot *Bot) editEvent(ctx context.Context, msg chat.Message, args []string) {
+	args, on := splitOn(args)
+	if len(args) < 3 {
+		bot.SendPriv(msg.Sender, editUsage)
+		return
+	}
+	rec, ok := bot.managedEvent(msg, args[0])
+	if !ok {
+		return
+	}
+	value := strings.Join(args[2:], " ")
+
+	// single occurrences are changed through their own id, the record keeps describing the series
+	eventID, start, end := rec.EventID, rec.Start, rec.End
+	if on != "" {
+		event, ok := bot.occurrence(ctx, msg, rec, on)
+		if !ok {
+			return
+		}
+		eventID, start, end = event.Id, calendar.StartTime(event), calendar.EndTime(event)
+	}
+
+	patch := &googlecal.Event{}
+	switch strings.ToLower(args[1]) {
+	case "title":
+		if err := validateTitle(value); err != nil {
+			bot.SendPriv(msg.Sender, err.Error())
+			return
+		}
+		patch.Summary = value
+	case "keywords":
+		patch.Description = value
+	case "start":
+		loc := bot.prefs(msg.Sender).location()
+		ref := time.Now()
+		if on != "" {
+			// a bare time of day moves the occurrence within its day
+			ref = calendar.Midnight(start.In(loc))
+		}
+		newStart, err := timeparse.Parse(value, ref, loc)
+		if err != nil {
+			bot.SendPriv(msg.Sender, fmt.Sprintf("I could not understand that (%v)", err))
+			return
+		}
+		if newStart.Before(time.Now()) {
+			bot.SendPriv(msg.Sender, "thats in the past FeelsPepoMan ")
+			return
+		}
+		var zone string
+		if on == "" && rec.Repeats != "" {
+			// the rule is checked and expanded in the timezone of the series
+			zone, err = bot.seriesZone(ctx, rec)
+			if err != nil {
+				logrus.Error("failed to fetch event", err)
+				bot.SendPriv(msg.Sender, fmt.Sprintf("could not edit event %v", err))
+				return
+			}
+			if loc, err := time.LoadLocation(zone); zone != "" && err == nil {
+				newStart = newStart.In(loc)
+			}
+			if rec.Rule != nil {
+				if err := rec.Rule.Matches(newStart); err != nil {
+					bot.SendPriv(msg.Sender, fmt.Sprintf("the series can't move there, %v", err))
+					return
+				}
+			}
+		}
+		patch.Start = calendar.EventTime(newStart)
+		patch.End = calendar.EventTime(newStart.Add(end.Sub(start)))
+		if zone != "" {
+			patch.Start.TimeZone, patch.End.TimeZone = zone, zone
+		}
+	case "duration":
+		duration, err := timeparse.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			bot.SendPriv(msg.Sender, "I could not understand that, provide the duration in the format `2h5m`")
+			return
+		}
+		patch.End = calendar.EventTime(start.Add(duration))
+		if on == "" && rec.Repeats != "" {
+			// the rule of a series is expanded in the timezone of its start, the end has to match it
+			zone, err := bot.seriesZone(ctx, rec)
+			if err != nil {
+				logrus.Error("failed to fetch event", err)
+				bot.SendPriv(msg.Sender, fmt.Sprintf("could not edit event %v", err))
+				return
+			}
+			patch.End.TimeZone = zone
+		}
+	default:
+		bot.SendPriv(msg.Sender, editUsage)
+		return
+	}
+
+	updated, err := bot.cal.UpdateEvent(ctx, rec.CalendarID, eventID, patch)
+	if err != nil {
+		logrus.Error("failed to edit event", err)
+		bot.SendPriv(msg.Sender, fmt.Sprintf("could not edit event %v", err))
+		return
+	}
+	if on != "" {
+		logrus.WithFields(logrus.Fields{
+			"chatter": msg.Sender,
+			"id":      rec.ID,
+			"on":      on,
+			"field":   args[1],
+			"value":   value,
+		}).Info("edited occurrence")
+		bot.SendPriv(msg.Sender, fmt.Sprintf("%s is now at %s on that day PepoG", updated.Summary, bot.prefs(msg.Sender).formatTime(calendar.StartTime(updated))))
+		return
+	}
+
+	rec.Title = updated.Summary
+	rec.Start = calendar.StartTime(updated)
+	rec.End = calendar.EndTime(updated)
+	rec.updateUntil()
+	if err := bot.store.Put(createdBucket, rec.ID, rec); err != nil {
+		logrus.Error("failed to record edited event", err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"id":      rec.ID,
+		"field":   args[1],
+		"value":   value,
+	}).Info("edited event")
+	bot.SendPriv(msg.Sender, fmt.Sprintf("%s is now at %s PepoG", rec.Title, bot.prefs(msg.Sender).formatTime(rec.Start)))
+}
+
+func (bot *Bot) deleteEvent(ctx context.Context, msg chat.Message, id, on string) {
+	rec, ok := bot.managedEvent(msg, id)
+	if !ok {
+		return
+	}
+	if on != "" {
+		bot.cancelOccurrence(ctx, msg, rec, on)
+		return
+	}
+
+	err := bot.cal.DeleteEvent(ctx, rec.CalendarID, rec.EventID)
+	if err != nil && !errors.Is(err, calendar.ErrEventNotFound) {
+		logrus.Error("failed to delete event", err)
+		bot.SendPriv(msg.Sender, fmt.Sprintf("could not delete event %v", err))
+		return
+	}
+	if err := bot.store.Delete(createdBucket, rec.ID); err != nil {
+		logrus.Error("failed to forget deleted event", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"id":      rec.ID,
+		"title":   rec.Title,
+	}).Info("deleted event")
+	bot.SendPriv(msg.Sender, fmt.Sprintf("deleted %s PepOk", rec.Title))
+}
+
+// cancelOccurrence removes a single occurrence of a repeating event
+func (bot *Bot) cancelOccurrence(ctx context.Context, msg chat.Message, rec createdEvent, on string) {
+	event, ok := bot.occurrence(ctx, msg, rec, on)
+	if !ok {
+		return
+	}
+	if err := bot.cal.DeleteEvent(ctx, rec.CalendarID, event.Id); err != nil && !errors.Is(err, calendar.ErrEventNotFound) {
+		logrus.Error("failed to cancel occurrence", err)
+		bot.SendPriv(msg.Sender, fmt.Sprintf("could not cancel event %v", err))
+		return
+	}
+
+	start := calendar.StartTime(event)
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"id":      rec.ID,
+		"start":   start,
+	}).Info("cancelled occurrence")
+	bot.SendPriv(msg.Sender, fmt.Sprintf("cancelled %s on %s PepOk", rec.Title, bot.prefs(msg.Sender).formatTime(start)))
+}
@@ -0,0 +1,199 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	bannedBucket  = "banned"
+	allowedBucket = "allowed"
+	// creationsBucket holds when chatters added events, deleting an event keeps its entry
+	creationsBucket = "creations"
+)
+
+// listEntry records who put a chatter on the ban or allow list
+type listEntry struct {
+	Nick   string       `json:"nick"`
+	By     chat.Chatter `json:"by"`
+	At     time.Time    `json:"at"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+func listKey(nick string) string {
+	return strings.ToLower(strings.TrimPrefix(nick, "@"))
+}
+
+// mayCreate returns why the sender may not add events, or nil if they may
+func (bot *Bot) mayCreate(msg chat.Message) error {
+	if bot.isMod(msg) {
+		return nil
+	}
+
+	if bot.banned(msg.Sender) {
+		return errors.New("you are not allowed to add events")
+	}
+	cfg := bot.cfg.Moderation
+	if cfg.AllowlistOnly {
+		if ok, _ := bot.store.Get(allowedBucket, listKey(string(msg.Sender)), &listEntry{}); !ok {
+			return errors.New("only approved chatters can add events, ask a mod")
+		}
+	}
+
+	if cfg.MaxPerDay <= 0 && cfg.MaxUpcoming <= 0 {
+		return nil
+	}
+	now := time.Now()
+	today := len(bot.creationsSince(msg.Sender, now.Add(-time.Hour*24)))
+	var upcoming int
+	for _, rec := range bot.createdBy(msg.Sender) {
+		if rec.upcoming(now) {
+			upcoming++
+		}
+	}
+	// submissions waiting for approval count as well
+	for _, p := range bot.pendingEvents(msg.Sender) {
+		if now.Sub(p.Submitted) < time.Hour*24 {
+			today++
+		}
+		upcoming++
+	}
+	if cfg.MaxPerDay > 0 && today >= cfg.MaxPerDay {
+		return fmt.Errorf("you can add at most %d events per day", cfg.MaxPerDay)
+	}
+	if cfg.MaxUpcoming > 0 && upcoming >= cfg.MaxUpcoming {
+		return fmt.Errorf("you can have at most %d upcoming events, delete some with `-delete`", cfg.MaxUpcoming)
+	}
+
+	return nil
+}
+
+// banned returns true if c is on the ban list
+func (bot *Bot) banned(c chat.Chatter) bool {
+	ok, err := bot.store.Get(bannedBucket, listKey(string(c)), &listEntry{})
+	if err != nil {
+		logrus.Error("failed to load ban", err)
+	}
+
+	return ok
+}
+
+// creationsSince returns when c added events after t
+func (bot *Bot) creationsSince(c chat.Chatter, t time.Time) []time.Time {
+	var times []time.Time
+	if _, err := bot.store.Get(creationsBucket, listKey(string(c)), &times); err != nil {
+		logrus.Error("failed to load creations", err)
+	}
+
+	recent := times[:0]
+	for _, at := range times {
+		if at.After(t) {
+			recent = append(recent, at)
+		}
+	}
+
+	return recent
+}
+
+// logCreation records that c added an event, entries older than a day are dropped
+func (bot *Bot) logCreation(c chat.Chatter, at time.Time) {
+	// mods approving submissions log creations of other chatters
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
+
+	times := append(bot.creationsSince(c, at.Add(-time.Hour*24)), at)
+	if err := bot.store.Put(creationsBucket, listKey(string(c)), times); err != nil {
+		logrus.Error("failed to log creation", err)
+	}
+}
+
+// updateList adds or removes a nick from the ban or allow list, only mods may do that
+func (bot *Bot) updateList(msg chat.Message, bucket string, add bool, args []string) {
+	if !bot.isMod(msg) {
+		bot.SendPriv(msg.Sender, "only mods can do that")
+		return
+	}
+	if len(args) == 0 {
+		bot.SendPriv(msg.Sender, "tell me who, e.g. `-ban SomeNick spamming events`")
+		return
+	}
+
+	nick := strings.TrimPrefix(args[0], "@")
+	key := listKey(nick)
+	var err error
+	if add {
+		err = bot.store.Put(bucket, key, listEntry{
+			Nick:   nick,
+			By:     msg.Sender,
+			At:     time.Now(),
+			Reason: strings.Join(args[1:], " "),
+		})
+	} else {
+		err = bot.store.Delete(bucket, key)
+	}
+	if err != nil {
+		logrus.Error("failed to update list", err)
+		bot.SendPriv(msg.Sender, "could not save that")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"mod":  msg.Sender,
+		"list": bucket,
+		"nick": nick,
+		"add":  add,
+	}).Info("updated list")
+	bot.SendPriv(msg.Sender, "PepOk")
+}
+
+// sendLists shows mods the ban and allow lists along with the quotas
+func (bot *Bot) sendLists(msg chat.Message) {
+	if !bot.isMod(msg) {
+		bot.SendPriv(msg.Sender, "only mods can do that")
+		return
+	}
+
+	cfg := bot.cfg.Moderation
+	bot.SendPriv(msg.Sender, fmt.Sprintf("banned: %s", bot.listNicks(bannedBucket)))
+	if cfg.AllowlistOnly {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("allowed: %s", bot.listNicks(allowedBucket)))
+	}
+	bot.SendPriv(msg.Sender, fmt.Sprintf("allowlist only: %t, approval: %t, events per day: %s, upcoming events: %s",
+		cfg.AllowlistOnly, cfg.Approval, fmtLimit(cfg.MaxPerDay), fmtLimit(cfg.MaxUpcoming)))
+}
+
+func (bot *Bot) listNicks(bucket string) string {
+	var nicks []string
+	err := bot.store.ForEach(bucket, func(_ string, raw json.RawMessage) error {
+		var entry listEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		nicks = append(nicks, entry.Nick)
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to load list", err)
+	}
+	if len(nicks) == 0 {
+		return "nobody"
+	}
+	sort.Strings(nicks)
+
+	return strings.Join(nicks, ", ")
+}
+
+func fmtLimit(n int) string {
+	if n <= 0 {
+		return "unlimited"
+	}
+
+	return fmt.Sprint(n)
+}
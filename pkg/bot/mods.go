@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	modsBucket = "mods"
+	// modExpiry is how long a chatter counts as mod after last being seen as one
+	modExpiry = time.Hour * 12
+	// modRefresh is how often the record of an active mod is renewed
+	modRefresh = time.Hour
+)
+
+// observeMod remembers chatters that showed up with the moderator feature, private
+// messages don't carry features, so mods are recognized there by this record.
+// Public messages without the feature clear the record, so demodded chatters lose
+// their powers as soon as they talk.
+func (bot *Bot) observeMod(msg chat.Message) {
+	if msg.Private {
+		return
+	}
+
+	key := strings.ToLower(string(msg.Sender))
+	if !msg.Mod() {
+		if err := bot.store.Delete(modsBucket, key); err != nil {
+			logrus.Error("failed to remove mod", err)
+		}
+		return
+	}
+
+	var seen time.Time
+	if ok, _ := bot.store.Get(modsBucket, key, &seen); ok && time.Since(seen) < modRefresh {
+		return
+	}
+	if err := bot.store.Put(modsBucket, key, time.Now()); err != nil {
+		logrus.Error("failed to save mod", err)
+	}
+}
+
+// isMod returns true if the sender of a message is a moderator
+func (bot *Bot) isMod(msg chat.Message) bool {
+	if msg.Mod() {
+		return true
+	}
+
+	var seen time.Time
+	ok, err := bot.store.Get(modsBucket, strings.ToLower(string(msg.Sender)), &seen)
+	if err != nil {
+		logrus.Error("failed to load mod", err)
+	}
+
+	return ok && time.Since(seen) < modExpiry
+}
@@ -0,0 +1,287 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	pendingBucket = "pending"
+	// defaultPendingTTL is how long submissions wait for a mod if no ttl is configured
+	defaultPendingTTL = time.Hour * 48
+	// maxPending limits the amount of submissions listed by -pending
+	maxPending = 10
+)
+
+// pendingEvent is an event submitted by a chatter that waits for a mod's approval
+type pendingEvent struct {
+	ID         string        `json:"id"`
+	Creator    chat.Chatter  `json:"creator"`
+	CalendarID string        `json:"calendarId"`
+	Title      string        `json:"title"`
+	Keywords   string        `json:"keywords"`
+	Start      time.Time     `json:"start"`
+	Duration   time.Duration `json:"duration"`
+	Submitted  time.Time     `json:"submitted"`
+	// Recurrence is set for repeating events
+	Recurrence *timeparse.Recurrence `json:"recurrence,omitempty"`
+}
+
+func (bot *Bot) needsApproval(msg chat.Message) bool {
+	return bot.cfg.Moderation.Approval && !bot.isMod(msg)
+}
+
+func (bot *Bot) pendingTTL() time.Duration {
+	if ttl := time.Duration(bot.cfg.Moderation.PendingTTL); ttl > 0 {
+		return ttl
+	}
+
+	return defaultPendingTTL
+}
+
+// submitEvent queues an event for approval and lets the mods know about it
+func (bot *Bot) submitEvent(msg chat.Message, e *eventEntry) {
+	p := pendingEvent{
+		ID:         util.ShortID(),
+		Creator:    msg.Sender,
+		CalendarID: e.calendar,
+		Title:      e.title,
+		Keywords:   e.searchKeywords,
+		Start:      e.time,
+		Duration:   e.duration,
+		Submitted:  time.Now(),
+		Recurrence: e.recurrence,
+	}
+	if err := bot.store.Put(pendingBucket, p.ID, p); err != nil {
+		logrus.Error("failed to save pending event", err)
+		bot.SendPriv(msg.Sender, "could not submit event, please try again later")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"chatter":  msg.Sender,
+		"calendar": p.CalendarID,
+		"title":    p.Title,
+		"start":    p.Start,
+		"id":       p.ID,
+	}).Info("submitted event")
+	bot.SendPriv(msg.Sender, "submitted PepoG a mod has to approve the event, I'll let you know")
+	bot.notifyMods(func(viewer prefs) string {
+		return fmt.Sprintf("%s submitted %s, see `-pending` or `-approve %s`", p.Creator, p.describe(viewer), p.ID)
+	})
+}
+
+// describe formats the submission in the timezone of the viewer
+func (p pendingEvent) describe(viewer prefs) string {
+	if p.Recurrence != nil {
+		return fmt.Sprintf("%s (%s, %s, %s)", p.Title, viewer.formatTime(p.Start), fmtShortDuration(p.Duration), p.Recurrence)
+	}
+
+	return fmt.Sprintf("%s (%s, %s)", p.Title, viewer.formatTime(p.Start), fmtShortDuration(p.Duration))
+}
+
+// fmtShortDuration formats a duration like 2h or 1h30m
+func fmtShortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+
+	return s
+}
+
+// notifyMods sends a private message to every chatter known to be a mod, formatted with their prefs
+func (bot *Bot) notifyMods(text func(viewer prefs) string) {
+	now := time.Now()
+	err := bot.store.ForEach(modsBucket, func(nick string, raw json.RawMessage) error {
+		var seen time.Time
+		if err := json.Unmarshal(raw, &seen); err != nil {
+			return err
+		}
+		if now.Sub(seen) < modExpiry {
+			bot.SendPriv(chat.Chatter(nick), text(bot.prefs(chat.Chatter(nick))))
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to notify mods", err)
+	}
+}
+
+// pendingEvents returns the submissions sorted by submission time, or those of one chatter if c is set
+func (bot *Bot) pendingEvents(c chat.Chatter) []pendingEvent {
+	var events []pendingEvent
+	err := bot.store.ForEach(pendingBucket, func(_ string, raw json.RawMessage) error {
+		var p pendingEvent
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		if c == "" || strings.EqualFold(string(p.Creator), string(c)) {
+			events = append(events, p)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to load pending events", err)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Submitted.Before(events[j].Submitted) })
+
+	return events
+}
+
+func (bot *Bot) listPending(msg chat.Message) {
+	if !bot.isMod(msg) {
+		bot.SendPriv(msg.Sender, "only mods can do that")
+		return
+	}
+
+	events := bot.pendingEvents("")
+	if len(events) == 0 {
+		bot.SendPriv(msg.Sender, "nothing is waiting for approval")
+		return
+	}
+	viewer := bot.prefs(msg.Sender)
+	lines := make([]string, 0, len(events))
+	for i, p := range events {
+		if i == maxPending {
+			lines = append(lines, fmt.Sprintf("and %d more", len(events)-maxPending))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("`%s` %s by %s", p.ID, p.describe(viewer), p.Creator))
+	}
+	bot.SendPriv(msg.Sender, strings.Join(lines, " | "))
+}
+
+// takePending loads and removes a submission for a mod, so two mods can't handle it at once
+func (bot *Bot) takePending(msg chat.Message, id string) (pendingEvent, bool) {
+	var p pendingEvent
+	if !bot.isMod(msg) {
+		bot.SendPriv(msg.Sender, "only mods can do that")
+		return p, false
+	}
+	if id == "" {
+		bot.SendPriv(msg.Sender, "tell me which event, see `-pending`")
+		return p, false
+	}
+
+	// two mods must not take the same event
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
+
+	ok, err := bot.store.Get(pendingBucket, id, &p)
+	if err != nil {
+		logrus.Error("failed to load pending event", err)
+	}
+	if !ok {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("there is no pending event `%s`, see `-pending`", id))
+		return p, false
+	}
+	if err := bot.store.Delete(pendingBucket, id); err != nil {
+		logrus.Error("failed to remove pending event", err)
+		bot.SendPriv(msg.Sender, "could not update the queue, please try again")
+		return p, false
+	}
+
+	return p, true
+}
+
+func (bot *Bot) approveEvent(ctx context.Context, msg chat.Message, id string) {
+	p, ok := bot.takePending(msg, id)
+	if !ok {
+		return
+	}
+	if p.Start.Before(time.Now()) {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("%s already started, I dropped it", p.Title))
+		bot.SendPriv(p.Creator, fmt.Sprintf("%s was not approved before it started FeelsBadMan", p.Title))
+		return
+	}
+	if bot.banned(p.Creator) {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("%s was banned after submitting %s, I dropped it", p.Creator, p.Title))
+		return
+	}
+
+	e := &eventEntry{
+		calendar:       p.CalendarID,
+		title:          p.Title,
+		searchKeywords: p.Keywords,
+		time:           p.Start,
+		duration:       p.Duration,
+		recurrence:     p.Recurrence,
+	}
+	createdID, err := bot.createEvent(ctx, p.Creator, e)
+	if err != nil {
+		if err := bot.store.Put(pendingBucket, p.ID, p); err != nil {
+			logrus.Error("failed to restore pending event", err)
+		}
+		logrus.Error("failed to add approved event", err)
+		bot.SendPriv(msg.Sender, fmt.Sprintf("could not add event %v", err))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"mod":     msg.Sender,
+		"chatter": p.Creator,
+		"id":      p.ID,
+	}).Info("approved event")
+	bot.SendPriv(msg.Sender, fmt.Sprintf("added %s PepOk", p.Title))
+	bot.SendPriv(p.Creator, fmt.Sprintf("%s was approved PepoG you can change it with `-edit %s` or `-delete %s`", p.Title, createdID, createdID))
+}
+
+func (bot *Bot) rejectEvent(msg chat.Message, args []string) {
+	var id string
+	if len(args) > 0 {
+		id = args[0]
+	}
+	p, ok := bot.takePending(msg, id)
+	if !ok {
+		return
+	}
+
+	reason := strings.Join(args[1:], " ")
+	logrus.WithFields(logrus.Fields{
+		"mod":     msg.Sender,
+		"chatter": p.Creator,
+		"id":      p.ID,
+		"reason":  reason,
+	}).Info("rejected event")
+	bot.SendPriv(msg.Sender, fmt.Sprintf("rejected %s PepOk", p.Title))
+	if reason != "" {
+		bot.SendPriv(p.Creator, fmt.Sprintf("%s was rejected: %s", p.Title, reason))
+	} else {
+		bot.SendPriv(p.Creator, fmt.Sprintf("%s was rejected", p.Title))
+	}
+}
+
+// expirePending drops submissions that waited too long or whose start has passed
+func (bot *Bot) expirePending(now time.Time) {
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
+
+	ttl := bot.pendingTTL()
+	for _, p := range bot.pendingEvents("") {
+		if now.Sub(p.Submitted) < ttl && now.Before(p.Start) {
+			continue
+		}
+		if err := bot.store.Delete(pendingBucket, p.ID); err != nil {
+			logrus.Error("failed to expire pending event", err)
+			continue
+		}
+		logrus.WithFields(logrus.Fields{
+			"chatter": p.Creator,
+			"id":      p.ID,
+		}).Info("expired pending event")
+		bot.SendPriv(p.Creator, fmt.Sprintf("%s was not approved in time FeelsBadMan", p.Title))
+	}
+}
@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/sirupsen/logrus"
+)
+
+const prefsBucket = "prefs"
+
+const (
+	verbosityShort = "short"
+	verbosityFull  = "full"
+)
+
+// prefs are the display settings of a chatter
+type prefs struct {
+	Timezone  string `json:"timezone,omitempty"`
+	Clock12   bool   `json:"clock12,omitempty"`
+	Verbosity string `json:"verbosity,omitempty"`
+}
+
+// location returns the chatter's timezone, or the default one if none is set
+func (p prefs) location() *time.Location {
+	if p.Timezone == "" {
+		return calendar.DefaultLocation
+	}
+	loc, err := timeparse.ParseZone(p.Timezone)
+	if err != nil {
+		return calendar.DefaultLocation
+	}
+
+	return loc
+}
+
+// absolute returns true if replies should include the absolute time. That is the
+// case for chatters that picked a timezone, unless they asked for short replies.
+func (p prefs) absolute() bool {
+	return p.Verbosity == verbosityFull || p.Verbosity == "" && p.Timezone != ""
+}
+
+// formatTime formats t in the chatter's timezone and clock
+func (p prefs) formatTime(t time.Time) string {
+	t = t.In(p.location())
+	if p.Clock12 {
+		return t.Format("Mon Jan 2 3:04pm MST")
+	}
+
+	return t.Format("Mon Jan 2 15:04 MST")
+}
+
+// formatClock formats the time of day of t in the chatter's timezone and clock
+func (p prefs) formatClock(t time.Time) string {
+	t = t.In(p.location())
+	if p.Clock12 {
+		return t.Format("3:04pm")
+	}
+
+	return t.Format("15:04")
+}
+
+func (p prefs) String() string {
+	tz := p.Timezone
+	if tz == "" {
+		tz = calendar.DefaultLocation.String() + " (default)"
+	}
+	clock := "24h"
+	if p.Clock12 {
+		clock = "12h"
+	}
+	verbosity := p.Verbosity
+	if verbosity == "" {
+		verbosity = "default"
+	}
+
+	return fmt.Sprintf("timezone: %s, clock: %s, details: %s", tz, clock, verbosity)
+}
+
+func prefsKey(c chat.Chatter) string {
+	return strings.ToLower(string(c))
+}
+
+// prefs returns the stored preferences of a chatter
+func (bot *Bot) prefs(c chat.Chatter) prefs {
+	var p prefs
+	if _, err := bot.store.Get(prefsBucket, prefsKey(c), &p); err != nil {
+		logrus.Error("failed to load prefs", err)
+	}
+
+	return p
+}
+
+// updatePrefs applies the -tz, -clock and -details settings of a message
+func (bot *Bot) updatePrefs(msg chat.Message, tz, clock, details string) {
+	p := bot.prefs(msg.Sender)
+
+	if tz != "" {
+		if strings.EqualFold(tz, "reset") {
+			p.Timezone = ""
+		} else {
+			loc, err := timeparse.ParseZone(tz)
+			if err != nil {
+				bot.SendPriv(msg.Sender, err.Error())
+				return
+			}
+			// store the region rather than an abbreviation so it keeps following dst
+			p.Timezone = loc.String()
+		}
+	}
+
+	switch clock {
+	case "":
+	case "12", "12h":
+		p.Clock12 = true
+	case "24", "24h":
+		p.Clock12 = false
+	default:
+		bot.SendPriv(msg.Sender, "the clock can be `12` or `24`")
+		return
+	}
+
+	switch details {
+	case "":
+	case "on", verbosityFull:
+		p.Verbosity = verbosityFull
+	case "off", verbosityShort:
+		p.Verbosity = verbosityShort
+	case "reset":
+		p.Verbosity = ""
+	default:
+		bot.SendPriv(msg.Sender, "details can be `on`, `off` or `reset`")
+		return
+	}
+
+	if err := bot.store.Put(prefsBucket, prefsKey(msg.Sender), p); err != nil {
+		logrus.Error("failed to save prefs", err)
+		bot.SendPriv(msg.Sender, "could not save your settings")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"prefs":   p.String(),
+	}).Info("updated prefs")
+	bot.SendPriv(msg.Sender, p.String())
+}
@@ -0,0 +1,291 @@
+package bot
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// queryCommand is the command name of plain event queries
+	queryCommand = "query"
+	// wizardCommand is the command name of answers to the add wizard, the -add that started
+	// the wizard was already charged so answers only count toward the sender limit
+	wizardCommand = "wizard"
+)
+
+var (
+	defaultSenderLimit = Limit{Count: 10, Per: util.Duration(time.Minute)}
+	defaultPublicLimit = Limit{Count: 6, Per: util.Duration(time.Minute)}
+	defaultIdkLimit    = Limit{Count: 1, Per: util.Duration(time.Minute)}
+)
+
+const (
+	defaultStrikes  = 3
+	defaultCooldown = time.Minute * 5
+)
+
+// limiter counts hits per key within sliding windows
+type limiter struct {
+	sync.Mutex
+	hits      map[string][]time.Time
+	offenders map[string]*offender
+}
+
+// offender tracks how often a chatter ran into a limit
+type offender struct {
+	strikes []time.Time
+	until   time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		hits:      make(map[string][]time.Time),
+		offenders: make(map[string]*offender),
+	}
+}
+
+// allow records a hit for key and returns true if it stays within the limit
+func (l *limiter) allow(key string, lim Limit, now time.Time) bool {
+	if lim.Count <= 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	hits := recent(l.hits[key], now.Add(-time.Duration(lim.Per)))
+	if len(hits) >= lim.Count {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+
+	return true
+}
+
+// strike records that a chatter hit a limit and returns their recent strikes, or 0 and the
+// end of their cooldown once they have too many
+func (l *limiter) strike(key string, cfg RateLimitConfig, now time.Time) (int, time.Time) {
+	l.Lock()
+	defer l.Unlock()
+
+	o, ok := l.offenders[key]
+	if !ok {
+		o = &offender{}
+		l.offenders[key] = o
+	}
+	o.strikes = append(recent(o.strikes, now.Add(-time.Duration(cfg.Cooldown))), now)
+	if len(o.strikes) >= cfg.Strikes {
+		o.strikes = nil
+		o.until = now.Add(time.Duration(cfg.Cooldown))
+	}
+
+	return len(o.strikes), o.until
+}
+
+// coolingDown returns true if a chatter is ignored at the moment
+func (l *limiter) coolingDown(key string, now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	o, ok := l.offenders[key]
+	return ok && now.Before(o.until)
+}
+
+// cooldowns returns the chatters that are ignored at the moment
+func (l *limiter) cooldowns(now time.Time) map[string]time.Time {
+	l.Lock()
+	defer l.Unlock()
+
+	res := make(map[string]time.Time)
+	for key, o := range l.offenders {
+		if now.Before(o.until) {
+			res[key] = o.until
+		}
+	}
+
+	return res
+}
+
+// prune forgets hits and strikes older than maxAge
+func (l *limiter) prune(now time.Time, maxAge time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	cutoff := now.Add(-maxAge)
+	for key, hits := range l.hits {
+		if len(recent(hits, cutoff)) == 0 {
+			delete(l.hits, key)
+		}
+	}
+	for key, o := range l.offenders {
+		if now.After(o.until) && len(recent(o.strikes, cutoff)) == 0 {
+			delete(l.offenders, key)
+		}
+	}
+}
+
+// recent drops the times before cutoff from a sorted slice
+func recent(times []time.Time, cutoff time.Time) []time.Time {
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(cutoff) })
+
+	return times[i:]
+}
+
+// pruneLimits lets the limiter forget about chatters that have been quiet for a while
+func (bot *Bot) pruneLimits(now time.Time) {
+	cfg := bot.rateLimits()
+	maxAge := time.Duration(cfg.Cooldown)
+	for _, lim := range append([]Limit{cfg.Sender, cfg.Public, cfg.Idk}, commandLimits(cfg)...) {
+		if time.Duration(lim.Per) > maxAge {
+			maxAge = time.Duration(lim.Per)
+		}
+	}
+	bot.limits.prune(now, maxAge)
+}
+
+func commandLimits(cfg RateLimitConfig) []Limit {
+	limits := make([]Limit, 0, len(cfg.Commands))
+	for _, lim := range cfg.Commands {
+		limits = append(limits, lim)
+	}
+
+	return limits
+}
+
+// rateLimits returns the rate limit settings with defaults filled in
+func (bot *Bot) rateLimits() RateLimitConfig {
+	cfg := bot.cfg.RateLimit
+	if cfg.Sender.Count == 0 {
+		cfg.Sender = defaultSenderLimit
+	}
+	if cfg.Public.Count == 0 {
+		cfg.Public = defaultPublicLimit
+	}
+	if cfg.Idk.Count == 0 {
+		cfg.Idk = defaultIdkLimit
+	}
+	if cfg.Strikes <= 0 {
+		cfg.Strikes = defaultStrikes
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = util.Duration(defaultCooldown)
+	}
+
+	return cfg
+}
+
+// commandFlags are the flags that pick a command, in the order process checks them. -cal and
+// -every only modify other commands.
+var commandFlags = []string{
+	"abort", "back",
+	"ban", "unban", "allow", "disallow", "lists", "pending", "approve", "reject", "limits", "alias",
+	"add", "list", "tz", "clock", "details", "prefs", "remind", "reminders", "unremind", "mine",
+	"edit", "delete", "follow", "following", "unfollow",
+	"today", "tomorrow", "on", "week", "at", "last", "ongoing", "ending", "multi",
+}
+
+// commandOf returns the name of the command process runs for the set flags, or queryCommand.
+// The order of the flags in the message does not matter.
+func commandOf(f *flag.FlagSet) string {
+	set := make(map[string]bool)
+	f.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+	for _, name := range commandFlags {
+		if set[name] {
+			return name
+		}
+	}
+
+	return queryCommand
+}
+
+// admit checks the limits of the sender and the command, and returns false if the
+// request should be dropped. Mods are not limited.
+func (bot *Bot) admit(msg chat.Message, cmd string) bool {
+	if bot.isMod(msg) {
+		return true
+	}
+
+	cfg := bot.rateLimits()
+	now := time.Now()
+	key := strings.ToLower(string(msg.Sender))
+	if bot.limits.coolingDown(key, now) {
+		return false
+	}
+	cmdLimit := cfg.Commands[cmd]
+	if cmd == wizardCommand {
+		cmdLimit = Limit{}
+	}
+	if bot.limits.allow("sender:"+key, cfg.Sender, now) &&
+		bot.limits.allow("command:"+cmd+":"+key, cmdLimit, now) {
+		return true
+	}
+
+	strikes, until := bot.limits.strike(key, cfg, now)
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"command": cmd,
+		"strikes": strikes,
+	}).Info("rate limited request")
+	switch {
+	case strikes == 0:
+		bot.SendPriv(msg.Sender, fmt.Sprintf("you are sending too many requests, I'll ignore you for %s", fmtShortDuration(until.Sub(now).Round(time.Second))))
+	case strikes == 1:
+		bot.SendPriv(msg.Sender, "slow down please, try again in a minute")
+	}
+
+	return false
+}
+
+// publicAllowed returns true if a public reply stays within the public channel's limit
+func (bot *Bot) publicAllowed(key string, lim Limit) bool {
+	return bot.limits.allow("public:"+key, lim, time.Now())
+}
+
+// sendLimits shows mods the rate limits and who is ignored at the moment
+func (bot *Bot) sendLimits(msg chat.Message) {
+	if !bot.isMod(msg) {
+		bot.SendPriv(msg.Sender, "only mods can do that")
+		return
+	}
+
+	cfg := bot.rateLimits()
+	rules := []string{
+		"per chatter: " + cfg.Sender.String(),
+		"public replies: " + cfg.Public.String(),
+		"public idk: " + cfg.Idk.String(),
+	}
+	cmds := make([]string, 0, len(cfg.Commands))
+	for cmd := range cfg.Commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Strings(cmds)
+	for _, cmd := range cmds {
+		rules = append(rules, fmt.Sprintf("-%s: %s", cmd, cfg.Commands[cmd]))
+	}
+	rules = append(rules, fmt.Sprintf("%s cooldown after %d strikes", fmtShortDuration(time.Duration(cfg.Cooldown)), cfg.Strikes))
+	bot.SendPriv(msg.Sender, strings.Join(rules, " | "))
+
+	now := time.Now()
+	cooldowns := bot.limits.cooldowns(now)
+	if len(cooldowns) == 0 {
+		bot.SendPriv(msg.Sender, "nobody is on cooldown")
+		return
+	}
+	nicks := make([]string, 0, len(cooldowns))
+	for nick, until := range cooldowns {
+		nicks = append(nicks, fmt.Sprintf("%s (%s)", nick, fmtShortDuration(until.Sub(now).Round(time.Second))))
+	}
+	sort.Strings(nicks)
+	bot.SendPriv(msg.Sender, "on cooldown: "+strings.Join(nicks, ", "))
+}
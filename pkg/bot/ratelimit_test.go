@@ -0,0 +1,177 @@
+package bot
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/util"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	lim := Limit{Count: 2, Per: util.Duration(time.Minute)}
+
+	tests := []struct {
+		name  string
+		key   string
+		lim   Limit
+		after time.Duration
+		want  bool
+	}{
+		{"first", "a", lim, 0, true},
+		{"second", "a", lim, time.Second, true},
+		{"over the limit", "a", lim, time.Second * 2, false},
+		{"other key", "b", lim, time.Second * 2, true},
+		{"still over", "a", lim, time.Second * 59, false},
+		// the first hit left the window
+		{"window slid", "a", lim, time.Second*60 + time.Millisecond*500, true},
+		{"full again", "a", lim, time.Second*60 + time.Millisecond*500, false},
+		{"unlimited", "a", Limit{Count: -1}, time.Second * 61, true},
+		{"zero is unlimited", "a", Limit{}, time.Second * 61, true},
+	}
+	l := newLimiter()
+	for _, tt := range tests {
+		if got := l.allow(tt.key, tt.lim, now.Add(tt.after)); got != tt.want {
+			t.Errorf("%s: allow() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterStrike(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	cfg := RateLimitConfig{Strikes: 3, Cooldown: util.Duration(time.Minute * 5)}
+	l := newLimiter()
+
+	tests := []struct {
+		after       time.Duration
+		strikes     int
+		coolingDown bool
+	}{
+		{0, 1, false},
+		{time.Second, 2, false},
+		// the third strike starts the cooldown
+		{time.Second * 2, 0, true},
+		{time.Minute*5 + time.Second*3, 1, false},
+		// strikes older than the cooldown are forgotten
+		{time.Minute*10 + time.Second*4, 1, false},
+	}
+	for i, tt := range tests {
+		at := now.Add(tt.after)
+		strikes, until := l.strike("a", cfg, at)
+		if strikes != tt.strikes {
+			t.Errorf("strike %d: got %d strikes, want %d", i, strikes, tt.strikes)
+		}
+		if strikes == 0 && !until.Equal(at.Add(time.Minute*5)) {
+			t.Errorf("strike %d: cooldown until %v", i, until)
+		}
+		if got := l.coolingDown("a", at.Add(time.Second)); got != tt.coolingDown {
+			t.Errorf("strike %d: coolingDown() = %v, want %v", i, got, tt.coolingDown)
+		}
+	}
+	if l.coolingDown("b", now) {
+		t.Error("b is cooling down without strikes")
+	}
+	if got := l.cooldowns(now.Add(time.Minute)); len(got) != 1 {
+		t.Errorf("cooldowns() = %v, want a", got)
+	}
+}
+
+func TestLimiterPrune(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	cfg := RateLimitConfig{Strikes: 1, Cooldown: util.Duration(time.Minute)}
+	lim := Limit{Count: 5, Per: util.Duration(time.Minute)}
+	l := newLimiter()
+
+	l.allow("old", lim, now.Add(-time.Minute*2))
+	l.allow("recent", lim, now.Add(-time.Second*30))
+	l.strike("expired", cfg, now.Add(-time.Minute*3))
+	l.strike("cooling", cfg, now.Add(-time.Second*30))
+	l.prune(now, time.Minute)
+
+	for key, want := range map[string]bool{"old": false, "recent": true} {
+		if _, ok := l.hits[key]; ok != want {
+			t.Errorf("hits of %s kept = %v, want %v", key, ok, want)
+		}
+	}
+	for key, want := range map[string]bool{"expired": false, "cooling": true} {
+		if _, ok := l.offenders[key]; ok != want {
+			t.Errorf("offender %s kept = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestCommandOf(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"formula 1", queryCommand},
+		{"-cal f1 formula 1", queryCommand},
+		{"-add", "add"},
+		{"-add -cal f1", "add"},
+		{"-remind -every f1 10m before", "remind"},
+		{"-on friday", "on"},
+		// the command process runs, not the first flag by name or position
+		{"-mine -remind", "remind"},
+		{"-limits -alias", "limits"},
+		{"-on friday -delete x", "delete"},
+	}
+	for _, tt := range tests {
+		f := flag.NewFlagSet("whenis", flag.ContinueOnError)
+		for _, name := range []string{"add", "remind", "every", "mine", "limits", "alias"} {
+			f.Bool(name, false, "")
+		}
+		f.String("delete", "", "")
+		f.String("cal", "", "")
+		f.String("on", "", "")
+		if err := f.Parse(strings.Fields(tt.args)); err != nil {
+			t.Fatal(err)
+		}
+		if got := commandOf(f); got != tt.want {
+			t.Errorf("commandOf(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	bot, out := newTestBot(t)
+	bot.cfg.RateLimit = RateLimitConfig{
+		Sender:   Limit{Count: 3, Per: util.Duration(time.Minute)},
+		Commands: map[string]Limit{"add": {Count: 1, Per: util.Duration(time.Minute)}},
+		Strikes:  2,
+		Cooldown: util.Duration(time.Minute),
+	}
+	chatter := chat.Message{Sender: "Chatter"}
+	mod := chat.Message{Sender: "mod", Features: []chat.UserFeature{chat.FeatureMod}}
+
+	tests := []struct {
+		name string
+		msg  chat.Message
+		cmd  string
+		want bool
+	}{
+		{"first add", chatter, "add", true},
+		{"second add", chatter, "add", false},
+		{"query", chatter, queryCommand, true},
+		// the fourth request is over the sender limit, its strike starts the cooldown
+		{"sender limit", chatter, queryCommand, false},
+		{"cooling down", chatter, "list", false},
+		{"nick case", chat.Message{Sender: "CHATTER"}, "list", false},
+		{"other chatter", chat.Message{Sender: "other"}, "add", true},
+		{"mod", mod, "add", true},
+		{"mod again", mod, "add", true},
+	}
+	for _, tt := range tests {
+		if got := bot.admit(tt.msg, tt.cmd); got != tt.want {
+			t.Errorf("%s: admit() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// a warning on the first strike and a notice once ignored, nothing while cooling down
+	if got := out.priv["Chatter"]; len(got) != 2 || !strings.HasPrefix(got[0], "slow down") || !strings.Contains(got[1], "ignore you for 1m") {
+		t.Errorf("chatter was told %q", got)
+	}
+}
@@ -0,0 +1,358 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	remindersBucket = "reminders"
+	// maxReminders limits the amount of reminders a single chatter can have
+	maxReminders = 20
+	// reminderRecheck is how often reminded events are fetched again to notice when they move
+	reminderRecheck = time.Minute * 5
+	// reminderGrace is how late a reminder may still be sent, e.g. after a restart
+	reminderGrace = time.Minute * 10
+)
+
+// errSeriesEnded is returned when a series has no occurrence left to remind of
+var errSeriesEnded = errors.New("the series has ended")
+
+type reminder struct {
+	ID          string        `json:"id"`
+	Chatter     chat.Chatter  `json:"chatter"`
+	CalendarID  string        `json:"calendarId"`
+	EventID     string        `json:"eventId"`
+	RecurringID string        `json:"recurringId,omitempty"`
+	Title       string        `json:"title"`
+	Start       time.Time     `json:"start"`
+	Lead        time.Duration `json:"lead"`
+	Checked     time.Time     `json:"checked"`
+	// Fired is set for series reminders that were sent, but not moved to the next occurrence yet
+	Fired bool `json:"fired,omitempty"`
+}
+
+func (r reminder) due() time.Time {
+	return r.Start.Add(-r.Lead)
+}
+
+// parseReminder splits the arguments of -remind, e.g. "Formula 1 10m before"
+func parseReminder(args []string) (string, time.Duration, error) {
+	if len(args) > 0 && strings.EqualFold(args[len(args)-1], "before") {
+		args = args[:len(args)-1]
+	}
+
+	for n := 1; n <= 2 && n < len(args); n++ {
+		lead, err := timeparse.ParseDuration(strings.Join(args[len(args)-n:], " "))
+		if err == nil && lead > 0 {
+			return strings.Join(args[:len(args)-n], " "), lead, nil
+		}
+	}
+
+	return "", 0, errors.New("tell me what and how long before, e.g. `-remind Formula 1 10m before`")
+}
+
+func (bot *Bot) addReminder(ctx context.Context, msg chat.Message, args []string, every bool) {
+	query, lead, err := parseReminder(args)
+	if err != nil {
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+	if len(bot.remindersOf(msg.Sender)) >= maxReminders {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("you can't have more than %d reminders, remove some with `-unremind`", maxReminders))
+		return
+	}
+
+	events, err := bot.cal.Query(ctx, query, 1)
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to handle request", err)
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+	if len(events) == 0 {
+		bot.SendPriv(msg.Sender, "idk SHRUG"+partialNote(err))
+		return
+	}
+	event := events[0]
+
+	r := reminder{
+		ID:         util.ShortID(),
+		Chatter:    msg.Sender,
+		CalendarID: bot.cal.CalendarOf(event),
+		EventID:    event.Id,
+		Title:      event.Summary,
+		Start:      calendar.StartTime(event),
+		Lead:       lead,
+		Checked:    time.Now(),
+	}
+
+	var note string
+	if every {
+		if event.RecurringEventId != "" {
+			r.RecurringID = event.RecurringEventId
+		} else {
+			note = " (it doesn't repeat, so just this once)"
+		}
+	}
+
+	now := time.Now()
+	if r.due().Before(now) {
+		if r.RecurringID == "" {
+			bot.SendPriv(msg.Sender, fmt.Sprintf("%s starts before that, in %s", r.Title, strings.TrimSpace(fmtDuration(r.Start.Sub(now)))))
+			return
+		}
+		// too late for this one, start with the next occurrence
+		r.Fired = true
+		if err := bot.advanceReminder(ctx, &r); errors.Is(err, errSeriesEnded) {
+			bot.SendPriv(msg.Sender, fmt.Sprintf("%s starts before that and the series has ended", r.Title))
+			return
+		} else if err != nil {
+			logrus.Error("failed to add reminder", err)
+			bot.SendPriv(msg.Sender, fmt.Sprintf("could not find the next occurrence of %s, try again later", r.Title))
+			return
+		}
+	}
+
+	if err := bot.store.Put(remindersBucket, r.ID, r); err != nil {
+		logrus.Error("failed to save reminder", err)
+		bot.SendPriv(msg.Sender, "could not save your reminder")
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"event":   r.EventID,
+		"lead":    r.Lead,
+		"series":  r.RecurringID != "",
+	}).Info("added reminder")
+
+	bot.SendPriv(msg.Sender, fmt.Sprintf("I'll remind you %s before %s (%s)%s, id `%s`",
+		strings.TrimSpace(fmtDuration(r.Lead)), r.Title, bot.prefs(msg.Sender).formatTime(r.Start), note, r.ID))
+}
+
+// remindersOf returns all reminders of a chatter
+func (bot *Bot) remindersOf(c chat.Chatter) []reminder {
+	var reminders []reminder
+	err := bot.store.ForEach(remindersBucket, func(_ string, raw json.RawMessage) error {
+		var r reminder
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		if strings.EqualFold(string(r.Chatter), string(c)) {
+			reminders = append(reminders, r)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to load reminders", err)
+	}
+
+	return reminders
+}
+
+func (bot *Bot) listReminders(msg chat.Message) {
+	reminders := bot.remindersOf(msg.Sender)
+	if len(reminders) == 0 {
+		bot.SendPriv(msg.Sender, "you have no reminders, add one with `-remind Formula 1 10m before`")
+		return
+	}
+
+	p := bot.prefs(msg.Sender)
+	lines := make([]string, 0, len(reminders))
+	for _, r := range reminders {
+		line := fmt.Sprintf("`%s` %s before %s (%s)", r.ID, strings.TrimSpace(fmtDuration(r.Lead)), r.Title, p.formatTime(r.Start))
+		if r.RecurringID != "" {
+			line += " every time"
+		}
+		lines = append(lines, line)
+	}
+	bot.SendPriv(msg.Sender, strings.Join(lines, " | "))
+}
+
+func (bot *Bot) removeReminder(msg chat.Message, id string) {
+	var removed int
+	for _, r := range bot.remindersOf(msg.Sender) {
+		if id != "all" && r.ID != id {
+			continue
+		}
+		if err := bot.store.Delete(remindersBucket, r.ID); err != nil {
+			logrus.Error("failed to delete reminder", err)
+			continue
+		}
+		removed++
+	}
+
+	if removed == 0 {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("you have no reminder `%s`, see `-reminders`", id))
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"id":      id,
+	}).Info("removed reminders")
+	bot.SendPriv(msg.Sender, "PepOk")
+}
+
+// checkReminders sends all due reminders and keeps the others in sync with their events
+func (bot *Bot) checkReminders(ctx context.Context, now time.Time) {
+	err := bot.store.ForEach(remindersBucket, func(_ string, raw json.RawMessage) error {
+		var r reminder
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		bot.checkReminder(ctx, r, now)
+		return nil
+	})
+	if err != nil {
+		logrus.Error("failed to check reminders", err)
+	}
+}
+
+func (bot *Bot) checkReminder(ctx context.Context, r reminder, now time.Time) {
+	if r.Fired {
+		bot.advanceSavedReminder(ctx, r)
+		return
+	}
+
+	soon := !now.Before(r.due().Add(-schedulerInterval))
+	if now.Sub(r.Checked) >= reminderRecheck || soon && now.Sub(r.Checked) >= schedulerInterval {
+		if !bot.refreshReminder(ctx, &r, now) {
+			return
+		}
+		if now.Before(r.due()) {
+			bot.saveReminder(r)
+		}
+	}
+
+	if now.Before(r.due()) {
+		return
+	}
+
+	if now.Sub(r.due()) <= reminderGrace {
+		p := bot.prefs(r.Chatter)
+		left := r.Start.Sub(now)
+		text := fmt.Sprintf("reminder: %s is starting now", r.Title)
+		if left.Round(time.Minute) > 0 {
+			text = fmt.Sprintf("reminder: %s starts in %s (%s)", r.Title, strings.TrimSpace(fmtDuration(left)), p.formatTime(r.Start))
+		}
+		bot.SendPriv(r.Chatter, text)
+		logrus.WithFields(logrus.Fields{
+			"chatter": r.Chatter,
+			"event":   r.EventID,
+		}).Info("sent reminder")
+	}
+
+	if r.RecurringID == "" {
+		if err := bot.store.Delete(remindersBucket, r.ID); err != nil {
+			logrus.Error("failed to delete reminder", err)
+		}
+		return
+	}
+	r.Fired = true
+	bot.advanceSavedReminder(ctx, r)
+}
+
+// refreshReminder fetches the reminded event again and follows it if it moved.
+// It returns false if the reminder is gone.
+func (bot *Bot) refreshReminder(ctx context.Context, r *reminder, now time.Time) bool {
+	event, err := bot.cal.Event(ctx, r.CalendarID, r.EventID)
+	if err != nil && !errors.Is(err, calendar.ErrEventNotFound) {
+		logrus.Error("failed to refresh reminder", err)
+		return true
+	}
+	r.Checked = now
+
+	if err != nil || event.Status == "cancelled" {
+		if r.RecurringID != "" {
+			// skip the cancelled occurrence
+			r.Fired = true
+			bot.advanceSavedReminder(ctx, *r)
+			return false
+		}
+		bot.SendPriv(r.Chatter, fmt.Sprintf("%s was cancelled, so I dropped your reminder", r.Title))
+		if err := bot.store.Delete(remindersBucket, r.ID); err != nil {
+			logrus.Error("failed to delete reminder", err)
+		}
+		return false
+	}
+
+	start := calendar.StartTime(event)
+	r.Title = event.Summary
+	if !start.Equal(r.Start) {
+		r.Start = start
+		if now.Before(r.due()) {
+			bot.SendPriv(r.Chatter, fmt.Sprintf("heads up: %s moved to %s, your reminder moved with it", r.Title, bot.prefs(r.Chatter).formatTime(start)))
+		}
+		logrus.WithFields(logrus.Fields{
+			"chatter": r.Chatter,
+			"event":   r.EventID,
+			"start":   start,
+		}).Info("rescheduled reminder")
+	}
+
+	return true
+}
+
+// advanceReminder moves a series reminder to the next occurrence. It returns errSeriesEnded
+// if there is none, or the error of fetching it, and leaves the reminder unchanged then.
+func (bot *Bot) advanceReminder(ctx context.Context, r *reminder) error {
+	// the next occurrence must be late enough to still send the reminder in time
+	after := r.Start
+	if earliest := time.Now().Add(r.Lead); after.Before(earliest) {
+		after = earliest
+	}
+
+	next, err := bot.cal.NextInstance(ctx, r.CalendarID, r.RecurringID, after)
+	if err != nil && !errors.Is(err, calendar.ErrEventNotFound) {
+		return fmt.Errorf("failed to find next occurrence: %w", err)
+	}
+	if next == nil {
+		return errSeriesEnded
+	}
+
+	r.EventID = next.Id
+	r.Title = next.Summary
+	r.Start = calendar.StartTime(next)
+	r.Checked = time.Now()
+	r.Fired = false
+
+	return nil
+}
+
+// advanceSavedReminder moves a stored series reminder to the next occurrence and drops it
+// once the series ended. If the next occurrence can't be fetched, the reminder is saved
+// as it is and tried again on the next check.
+func (bot *Bot) advanceSavedReminder(ctx context.Context, r reminder) {
+	err := bot.advanceReminder(ctx, &r)
+	if errors.Is(err, errSeriesEnded) {
+		bot.SendPriv(r.Chatter, fmt.Sprintf("there are no more occurrences of %s, so I dropped your reminder", r.Title))
+		if err := bot.store.Delete(remindersBucket, r.ID); err != nil {
+			logrus.Error("failed to delete reminder", err)
+		}
+		return
+	}
+	if err != nil {
+		logrus.Error(err)
+	}
+	bot.saveReminder(r)
+}
+
+// saveReminder stores a reminder, unless it was removed in the meantime
+func (bot *Bot) saveReminder(r reminder) {
+	var existing reminder
+	if ok, err := bot.store.Get(remindersBucket, r.ID, &existing); !ok || err != nil {
+		return
+	}
+	if err := bot.store.Put(remindersBucket, r.ID, r); err != nil {
+		logrus.Error("failed to save reminder", err)
+	}
+}
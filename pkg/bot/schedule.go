@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	googlecal "google.golang.org/api/calendar/v3"
+
+	"github.com/MemeLabs/whenis/pkg/calendar"
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/sirupsen/logrus"
+)
+
+// scheduleDays is the length of the range listed by -week
+const scheduleDays = 7
+
+// sendDay lists the events of the day offset days from today, or of the day described by date
+func (bot *Bot) sendDay(ctx context.Context, msg chat.Message, offset int, date, query string) {
+	p := bot.prefs(msg.Sender)
+	now := time.Now()
+	day := calendar.Midnight(now.In(p.location())).AddDate(0, 0, offset)
+	label := "today"
+	if offset == 1 {
+		label = "tomorrow"
+	}
+	if date != "" {
+		var err error
+		day, err = timeparse.ParseDate(date, now, p.location())
+		if err != nil {
+			bot.SendPriv(msg.Sender, fmt.Sprintf("I could not understand that (%v), try `-on friday` or `-on 2006-01-02`", err))
+			return
+		}
+		label = day.Format("Mon Jan 2")
+	}
+
+	bot.sendSchedule(ctx, msg, label, query, day, day.AddDate(0, 0, 1))
+}
+
+// sendWeek lists the events of the next days starting today
+func (bot *Bot) sendWeek(ctx context.Context, msg chat.Message, query string) {
+	today := calendar.Midnight(time.Now().In(bot.prefs(msg.Sender).location()))
+	bot.sendSchedule(ctx, msg, "this week", query, today, today.AddDate(0, 0, scheduleDays))
+}
+
+// sendAt lists the events that take place at the moment described by at
+func (bot *Bot) sendAt(ctx context.Context, msg chat.Message, at string) {
+	p := bot.prefs(msg.Sender)
+	t, err := timeparse.Parse(at, time.Now(), p.location())
+	if err != nil {
+		bot.SendPriv(msg.Sender, fmt.Sprintf("I could not understand that (%v), try `-at 21:00 CET`", err))
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"at":      t,
+	}).Info("got an at request")
+
+	candidates, err := bot.cal.Between(ctx, "", t, t.Add(time.Second))
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to handle request", err)
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+	var events []*googlecal.Event
+	for _, event := range candidates {
+		if calendar.EventSpan(event).Ongoing(t) {
+			events = append(events, event)
+		}
+	}
+
+	label := p.formatTime(t)
+	if len(events) == 0 {
+		bot.reply(msg, fmt.Sprintf("nothing is on at %s%s", label, partialNote(err)))
+		return
+	}
+	entries := make([]string, 0, len(events))
+	for _, event := range events {
+		span := calendar.EventSpan(event)
+		if span.AllDay {
+			entries = append(entries, event.Summary+" (all day)")
+		} else {
+			entries = append(entries, fmt.Sprintf("%s (%s-%s)", event.Summary, p.formatClock(span.Start), p.formatClock(span.End)))
+		}
+	}
+	bot.replyLong(msg, fmt.Sprintf("at %s: %s%s", label, strings.Join(entries, " | "), partialNote(err)))
+}
+
+func (bot *Bot) sendSchedule(ctx context.Context, msg chat.Message, label, query string, from, to time.Time) {
+	logrus.WithFields(logrus.Fields{
+		"chatter": msg.Sender,
+		"from":    from,
+		"to":      to,
+		"query":   query,
+	}).Info("got a schedule request")
+
+	events, err := bot.cal.Between(ctx, bot.resolveAliases(query), from, to)
+	if err != nil && !calendar.IsPartial(err) {
+		logrus.Error("failed to handle request", err)
+		bot.SendPriv(msg.Sender, err.Error())
+		return
+	}
+	if len(events) == 0 {
+		bot.reply(msg, fmt.Sprintf("%s: nothing on the calendar%s", label, partialNote(err)))
+		return
+	}
+
+	p := bot.prefs(msg.Sender)
+	multiDay := to.Sub(from) > time.Hour*24
+	entries := make([]string, 0, len(events))
+	for _, event := range events {
+		span := calendar.EventSpan(event)
+		var when string
+		switch {
+		case span.AllDay && multiDay:
+			when = span.Start.Format("Mon") + " all day"
+		case span.AllDay:
+			when = "all day"
+		case span.Start.Before(from):
+			when = "until " + p.formatClock(span.End)
+		case multiDay:
+			when = span.Start.In(p.location()).Format("Mon ") + p.formatClock(span.Start)
+		default:
+			when = p.formatClock(span.Start)
+		}
+		entries = append(entries, when+" "+event.Summary)
+	}
+	bot.replyLong(msg, fmt.Sprintf("%s (%s): %s%s", label, p.location(), strings.Join(entries, " | "), partialNote(err)))
+}
+
+// replyLong replies with text split into several messages if it is too long for one
+func (bot *Bot) replyLong(msg chat.Message, text string) {
+	for _, part := range splitMessage(text, defaultMaxLength, " | ") {
+		bot.reply(msg, part)
+	}
+}
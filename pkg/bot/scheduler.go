@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"context"
+	"time"
+)
+
+// schedulerInterval is how often the bot checks whether it has something to say on its own
+const schedulerInterval = time.Second * 30
+
+func (bot *Bot) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			tickCtx, cancel := context.WithTimeout(ctx, schedulerInterval)
+			bot.tick(tickCtx, now)
+			cancel()
+		}
+	}
+}
+
+// tick runs all scheduled jobs once
+func (bot *Bot) tick(ctx context.Context, now time.Time) {
+	bot.syncEvents(ctx, now)
+	bot.checkReminders(ctx, now)
+	bot.announceEvents(ctx, now)
+	bot.postDigests(ctx, now)
+	bot.expirePending(now)
+	bot.pruneLimits(now)
+	bot.expireChoices(now)
+	bot.expireWizards(now)
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// sessionQueue limits the messages of a chatter waiting to be answered
+	sessionQueue = 8
+	// sessionIdle is how long a chatter's worker waits for more messages before it stops
+	sessionIdle = time.Minute
+)
+
+// sessions answer the messages of every chatter in order, while different chatters are
+// answered concurrently
+type sessions struct {
+	sync.Mutex
+	queues map[chat.Chatter]chan chat.Message
+}
+
+func newSessions() *sessions {
+	return &sessions{queues: make(map[chat.Chatter]chan chat.Message)}
+}
+
+// dispatch queues msg for its sender, and starts a worker for the sender if there is none
+func (bot *Bot) dispatch(ctx context.Context, msg chat.Message) {
+	if bot.enqueue(ctx, msg) {
+		return
+	}
+
+	logrus.WithField("chatter", msg.Sender).Info("dropped message, too many queued")
+	// one notice per minute, so flooding the bot doesn't make it flood back
+	key := "busy:" + strings.ToLower(string(msg.Sender))
+	if bot.limits.allow(key, Limit{Count: 1, Per: util.Duration(time.Minute)}, time.Now()) {
+		bot.SendPriv(msg.Sender, "you are sending messages faster than I can answer, wait for my replies and try again")
+	}
+}
+
+// enqueue adds msg to the queue of its sender, it returns false if the queue is full
+func (bot *Bot) enqueue(ctx context.Context, msg chat.Message) bool {
+	bot.sessions.Lock()
+	defer bot.sessions.Unlock()
+
+	q, ok := bot.sessions.queues[msg.Sender]
+	if !ok {
+		q = make(chan chat.Message, sessionQueue)
+		bot.sessions.queues[msg.Sender] = q
+		go bot.serve(ctx, msg.Sender, q)
+	}
+	select {
+	case q <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// serve answers the queued messages of a chatter one after another
+func (bot *Bot) serve(ctx context.Context, c chat.Chatter, q chan chat.Message) {
+	idle := time.NewTimer(sessionIdle)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-q:
+			reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+			bot.process(reqCtx, msg)
+			cancel()
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(sessionIdle)
+		case <-idle.C:
+			// dispatch only queues while holding the lock, so nothing is lost once the queue is gone
+			bot.sessions.Lock()
+			if len(q) == 0 {
+				delete(bot.sessions.queues, c)
+				bot.sessions.Unlock()
+				return
+			}
+			bot.sessions.Unlock()
+			idle.Reset(sessionIdle)
+		}
+	}
+}
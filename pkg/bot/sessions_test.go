@@ -0,0 +1,235 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	googlecal "google.golang.org/api/calendar/v3"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/MemeLabs/whenis/pkg/util"
+)
+
+// waitFor polls until cond holds or the test times out
+func (r *recorder) waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 10)
+	for time.Now().Before(deadline) {
+		r.Lock()
+		ok := cond()
+		r.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("timed out waiting for replies")
+}
+
+func testChatters(n int) []chat.Chatter {
+	chatters := make([]chat.Chatter, n)
+	for i := range chatters {
+		chatters[i] = chat.Chatter(fmt.Sprintf("chatter%d", i))
+	}
+
+	return chatters
+}
+
+func TestConcurrentWizards(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bot, out := newTestBot(t)
+	chatters := testChatters(8)
+
+	answers := func(c chat.Chatter) []string {
+		return []string{string(c) + " night", string(c) + " keywords", "tomorrow 20:00 UTC", "2h", "no"}
+	}
+	var wg sync.WaitGroup
+	for _, c := range chatters {
+		wg.Add(1)
+		go func(c chat.Chatter) {
+			defer wg.Done()
+			bot.startWizard(chat.Message{Sender: c, Private: true}, "cal-"+string(c))
+			for _, a := range answers(c) {
+				bot.dispatch(ctx, chat.Message{Sender: c, Private: true, Data: a})
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	// the first prompt and an answer to each step
+	want := len(answers("")) + 1
+	out.waitFor(t, func() bool {
+		for _, c := range chatters {
+			if len(out.priv[c]) < want {
+				return false
+			}
+		}
+		return true
+	})
+
+	bot.additionsLock.Lock()
+	defer bot.additionsLock.Unlock()
+	for _, c := range chatters {
+		w, ok := bot.ongoingAdditions[c]
+		if !ok {
+			t.Fatalf("%s lost their wizard", c)
+		}
+		if w.step != stepConfirm {
+			t.Errorf("%s is at step %d, want %d", c, w.step, stepConfirm)
+		}
+		e := w.entry
+		if e.title != string(c)+" night" || e.searchKeywords != string(c)+" keywords" || e.calendar != "cal-"+string(c) {
+			t.Errorf("%s got the wrong event %q %q %q", c, e.title, e.searchKeywords, e.calendar)
+		}
+		if e.duration != time.Hour*2 || e.recurrence != nil {
+			t.Errorf("%s got duration %v and recurrence %v", c, e.duration, e.recurrence)
+		}
+
+		out.Lock()
+		replies := out.priv[c]
+		out.Unlock()
+		if last := replies[len(replies)-1]; !strings.Contains(last, string(c)+" night") {
+			t.Errorf("%s was asked to confirm %q", c, last)
+		}
+		for _, r := range replies {
+			for _, other := range chatters {
+				if other != c && strings.Contains(r, string(other)+" ") {
+					t.Errorf("%s got a reply meant for %s: %q", c, other, r)
+				}
+			}
+		}
+	}
+}
+
+func TestWizardUnderCommandLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bot, out := newTestBot(t)
+	// the example limits of the README
+	bot.cfg.RateLimit = RateLimitConfig{
+		Commands: map[string]Limit{"add": {Count: 2, Per: util.Duration(time.Minute * 10)}},
+	}
+	c := chat.Chatter("chatter")
+
+	msg := chat.Message{Sender: c, Private: true, Data: "-add"}
+	if !bot.admit(msg, "add") {
+		t.Fatal("-add was not admitted")
+	}
+	bot.startWizard(msg, "cal")
+	answers := []string{"game night", "games", "tomorrow 20:00 UTC", "-back", "tomorrow 21:00 UTC", "2h", "no", "-edit title", "quiz night"}
+	for i, a := range answers {
+		bot.dispatch(ctx, chat.Message{Sender: c, Private: true, Data: a})
+		// one answer at a time, the queue of a chatter is short
+		out.waitFor(t, func() bool { return len(out.priv[c]) >= i+2 })
+	}
+
+	out.Lock()
+	defer out.Unlock()
+	for _, r := range out.priv[c] {
+		if strings.Contains(r, "slow down") || strings.Contains(r, "ignore you") {
+			t.Errorf("wizard answers ran into a limit: %q", r)
+		}
+	}
+	if last := out.priv[c][len(out.priv[c])-1]; !strings.Contains(last, "quiz night") {
+		t.Errorf("asked to confirm %q", last)
+	}
+}
+
+func TestConcurrentChoices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bot, out := newTestBot(t)
+	chatters := testChatters(8)
+
+	start := time.Now().Add(time.Hour * 3).Format(time.RFC3339)
+	bot.choices.Lock()
+	for _, c := range chatters {
+		o := &offer{expires: time.Now().Add(choiceWindow)}
+		for i := 1; i <= 3; i++ {
+			o.events = append(o.events, &googlecal.Event{
+				Summary: fmt.Sprintf("%s event %d", c, i),
+				Start:   &googlecal.EventDateTime{DateTime: start},
+				End:     &googlecal.EventDateTime{DateTime: start},
+			})
+		}
+		bot.choices.offers[c] = o
+	}
+	bot.choices.Unlock()
+
+	// odd chatters pick in public, even ones in private, everyone picks the second event
+	var wg sync.WaitGroup
+	for i, c := range chatters {
+		wg.Add(1)
+		go func(c chat.Chatter, private bool) {
+			defer wg.Done()
+			bot.dispatch(ctx, chat.Message{Sender: c, Private: private, Data: "2"})
+		}(c, i%2 == 0)
+	}
+	wg.Wait()
+
+	out.waitFor(t, func() bool {
+		n := len(out.public)
+		for i, c := range chatters {
+			if i%2 == 0 && len(out.priv[c]) > 0 {
+				n++
+			}
+		}
+		return n == len(chatters)
+	})
+
+	bot.choices.Lock()
+	if len(bot.choices.offers) != 0 {
+		t.Errorf("%d offers were not taken", len(bot.choices.offers))
+	}
+	bot.choices.Unlock()
+
+	out.Lock()
+	defer out.Unlock()
+	picked := make(map[string]bool)
+	for _, msg := range out.public {
+		picked[strings.SplitN(msg, " is in ", 2)[0]] = true
+	}
+	for i, c := range chatters {
+		want := fmt.Sprintf("%s event 2", c)
+		if i%2 == 0 {
+			if got := out.priv[c]; len(got) != 1 || !strings.HasPrefix(got[0], want+" is in ") {
+				t.Errorf("%s got %q, want their pick", c, got)
+			}
+			continue
+		}
+		if !picked[want] {
+			t.Errorf("the public pick of %s is missing from %q", c, out.public)
+		}
+	}
+}
+
+func TestConcurrentIdk(t *testing.T) {
+	bot, out := newTestBot(t)
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n*2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.Send(bot.idk())
+		}()
+	}
+	wg.Wait()
+
+	shrugs := 0
+	for _, msg := range out.public {
+		if strings.HasPrefix(msg, "idk SHRUG") {
+			shrugs++
+		}
+	}
+	// the emote alternates, so both emotes are used equally often
+	if len(out.public) != n*2 || shrugs != n {
+		t.Errorf("got %d idk SHRUG of %d replies, want %d of %d", shrugs, len(out.public), n, n*2)
+	}
+}
@@ -0,0 +1,278 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/chat"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// wizardRemindAfter is how long a wizard waits for an answer before reminding the chatter
+	wizardRemindAfter = time.Minute * 10
+	// wizardTTL is how long a wizard waits for an answer before it is dropped
+	wizardTTL = time.Minute * 30
+)
+
+// wizardStep is the question a wizard is waiting for an answer to
+type wizardStep int
+
+const (
+	stepTitle wizardStep = iota
+	stepKeywords
+	stepStart
+	stepDuration
+	stepRepeat
+	stepConfirm
+)
+
+// wizardSteps are the steps that can be changed with -edit
+var wizardSteps = map[string]wizardStep{
+	"title":    stepTitle,
+	"keywords": stepKeywords,
+	"start":    stepStart,
+	"duration": stepDuration,
+	"repeat":   stepRepeat,
+	"repeats":  stepRepeat,
+}
+
+// wizard asks a chatter for the parts of an event one by one
+type wizard struct {
+	entry *eventEntry
+	step  wizardStep
+	// reached is the first step that was never answered, answers to earlier steps return there
+	reached  wizardStep
+	active   time.Time
+	reminded bool
+}
+
+// startWizard starts asking the sender for an event to add to calendar
+func (bot *Bot) startWizard(msg chat.Message, calendar string) {
+	w := &wizard{
+		entry:  &eventEntry{calendar: calendar},
+		active: time.Now(),
+	}
+	prompt := w.prompt(bot.prefs(msg.Sender))
+
+	bot.additionsLock.Lock()
+	bot.ongoingAdditions[msg.Sender] = w
+	bot.additionsLock.Unlock()
+
+	bot.SendPriv(msg.Sender, prompt+" (you can go back a step with `-back` and stop any time using `-abort`)")
+}
+
+// addingEvent returns whether c is in the middle of adding an event
+func (bot *Bot) addingEvent(c chat.Chatter) bool {
+	bot.additionsLock.Lock()
+	defer bot.additionsLock.Unlock()
+
+	_, ok := bot.ongoingAdditions[c]
+	return ok
+}
+
+// abortWizard drops the wizard of the sender, if there is one
+func (bot *Bot) abortWizard(msg chat.Message) {
+	bot.additionsLock.Lock()
+	defer bot.additionsLock.Unlock()
+
+	delete(bot.ongoingAdditions, msg.Sender)
+}
+
+// continueAddingEvent handles a message of a chatter that is adding an event, it returns
+// false if the sender isn't adding one
+func (bot *Bot) continueAddingEvent(ctx context.Context, msg chat.Message, back, edit bool, args []string) bool {
+	// replies are prepared under the lock and sent after it, a slow chat must not stall other wizards
+	adding, reply, confirmed := bot.advanceWizard(msg, bot.prefs(msg.Sender), back, edit, args)
+	if reply != "" {
+		bot.SendPriv(msg.Sender, reply)
+	}
+	if confirmed != nil {
+		bot.finishEvent(ctx, msg, confirmed)
+	}
+
+	return adding
+}
+
+// advanceWizard answers the wizard of the sender. It returns whether the sender is adding
+// an event, the reply to send and the entry to add once it is confirmed.
+func (bot *Bot) advanceWizard(msg chat.Message, p prefs, back, edit bool, args []string) (bool, string, *eventEntry) {
+	bot.additionsLock.Lock()
+	defer bot.additionsLock.Unlock()
+
+	w, ok := bot.ongoingAdditions[msg.Sender]
+	if !ok {
+		return false, "", nil
+	}
+	w.active = time.Now()
+	w.reminded = false
+
+	switch {
+	case back:
+		if w.step == stepTitle {
+			return true, "this is the first step, use `-abort` to stop adding the event", nil
+		}
+		w.step--
+		return true, w.prompt(p), nil
+	case edit:
+		step, ok := wizardSteps[strings.ToLower(strings.Join(args, " "))]
+		if !ok {
+			return true, "use `-edit title`, `-edit keywords`, `-edit start`, `-edit duration` or `-edit repeats`", nil
+		}
+		if step >= w.reached {
+			return true, "you did not get to that yet, " + w.prompt(p), nil
+		}
+		w.step = step
+		return true, w.prompt(p), nil
+	}
+
+	data := strings.TrimSpace(msg.WithoutNick(bot.name))
+	if w.step == stepConfirm {
+		reply, confirmed := bot.confirmEvent(msg, w, p, data)
+		return true, reply, confirmed
+	}
+	note, err := w.answer(data, p, time.Now())
+	if err != nil {
+		return true, wizardRetry(err), nil
+	}
+	w.advance()
+
+	return true, note + w.prompt(p), nil
+}
+
+// answer fills the current step of the wizard with data, it returns a note to put in front of the next question
+func (w *wizard) answer(data string, p prefs, now time.Time) (string, error) {
+	e := w.entry
+	switch w.step {
+	case stepTitle:
+		return "", e.setTitle(data)
+	case stepKeywords:
+		e.setKeywords(data)
+	case stepStart:
+		if err := e.setStart(data, now, p.location()); err != nil {
+			return "", err
+		}
+		note := fmt.Sprintf("got it, that's %v (in %v). ", p.formatTime(e.time), strings.TrimSpace(fmtDuration(e.time.Sub(now))))
+		if e.recurrence != nil && e.recurrence.Matches(e.time) != nil {
+			e.recurrence = nil
+			w.reached = stepRepeat
+			note += "the repetition does not fit the new start anymore. "
+		}
+		return note, nil
+	case stepDuration:
+		return "", e.setDuration(data)
+	case stepRepeat:
+		return "", e.setRecurrence(data, now)
+	}
+
+	return "", nil
+}
+
+// advance moves on to the next unanswered step, or back to where the chatter was before an edit
+func (w *wizard) advance() {
+	if w.step < w.reached {
+		w.step = w.reached
+		return
+	}
+	w.step++
+	w.reached = w.step
+}
+
+// prompt is the question of the current step, steps that were answered before show their answer
+func (w *wizard) prompt(p prefs) string {
+	e := w.entry
+	var q, current string
+	switch w.step {
+	case stepTitle:
+		q, current = "what should the title be?", e.title
+	case stepKeywords:
+		q, current = "what search keywords should the event have?", e.searchKeywords
+	case stepStart:
+		q = "at what time does it start? you can write things like `tomorrow 8pm EST`, `friday 20:00 Europe/Berlin`, `next sat noon`, `in 2h5m`, `2006-01-02T15:04:05Z` (RFC3339) or `1630006096` (unix)"
+		if !e.time.IsZero() {
+			current = p.formatTime(e.time)
+		}
+	case stepDuration:
+		q = "how long will the event last? provide the duration in the format `2h5m`"
+		if e.duration > 0 {
+			current = fmtShortDuration(e.duration)
+		}
+	case stepRepeat:
+		q = "does it repeat? you can write things like `every tuesday`, `weekdays`, `every 2 weeks until december`, `daily 5 times` or `no`"
+		if w.reached > stepRepeat {
+			current = "no"
+			if e.recurrence != nil {
+				current = e.recurrence.String()
+			}
+		}
+	case stepConfirm:
+		return fmt.Sprintf("%s | add it? answer `yes` or `no`, or change something with `-edit title`, `-edit keywords`, `-edit start`, `-edit duration` or `-edit repeats`", e.summary(p))
+	}
+	if current != "" {
+		q += fmt.Sprintf(" (currently %s)", current)
+	}
+
+	return q
+}
+
+// confirmEvent ends a finished wizard, additionsLock must be held. It returns the reply,
+// and the entry if the chatter agrees to add it.
+func (bot *Bot) confirmEvent(msg chat.Message, w *wizard, p prefs, answer string) (string, *eventEntry) {
+	switch strings.ToLower(answer) {
+	case "yes", "y":
+		delete(bot.ongoingAdditions, msg.Sender)
+		logrus.WithFields(logrus.Fields{
+			"chatter":  msg.Sender,
+			"calendar": w.entry.calendar,
+			"title":    w.entry.title,
+		}).Info("confirmed event")
+		return "", w.entry
+	case "no", "n":
+		delete(bot.ongoingAdditions, msg.Sender)
+		return "PepOk dropped it", nil
+	default:
+		return w.prompt(p), nil
+	}
+}
+
+// wizardRetry asks to answer the current question again
+func wizardRetry(err error) string {
+	if err == errPast {
+		return err.Error()
+	}
+
+	return err.Error() + ", please try again."
+}
+
+// expireWizards reminds chatters of wizards they left unanswered, and drops them once they time out
+func (bot *Bot) expireWizards(now time.Time) {
+	var dropped []chat.Chatter
+	reminders := make(map[chat.Chatter]wizard)
+
+	bot.additionsLock.Lock()
+	for c, w := range bot.ongoingAdditions {
+		idle := now.Sub(w.active)
+		switch {
+		case idle > wizardTTL:
+			delete(bot.ongoingAdditions, c)
+			dropped = append(dropped, c)
+		case idle > wizardRemindAfter && !w.reminded:
+			w.reminded = true
+			// the prompt is formatted after unlocking, from a copy the chatter can't change meanwhile
+			snapshot, entry := *w, *w.entry
+			snapshot.entry = &entry
+			reminders[c] = snapshot
+		}
+	}
+	bot.additionsLock.Unlock()
+
+	for _, c := range dropped {
+		logrus.WithField("chatter", c).Info("dropped abandoned event")
+		bot.SendPriv(c, "I stopped adding your event since you did not answer, start over with `-add`")
+	}
+	for c, w := range reminders {
+		bot.SendPriv(c, fmt.Sprintf("you are still adding an event, %s (or `-abort` to stop)", w.prompt(bot.prefs(c))))
+	}
+}
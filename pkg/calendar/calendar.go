@@ -1,16 +1,17 @@
 package calendar
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/MemeLabs/whenis/pkg/util"
+	"github.com/MemeLabs/whenis/pkg/search"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
 	"github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/calendar/v3"
 	"google.golang.org/api/googleapi"
@@ -21,12 +22,29 @@ type Calendar struct {
 	*calendar.Service
 	sync.RWMutex
 
+	cfg Config
+
 	calListEtag  string
 	lastRefresh  time.Time
 	subCalendars []*calendar.CalendarListEntry
+
+	breakerLock sync.Mutex
+	breakers    map[string]*breaker
+
+	eventLock   sync.Mutex
+	eventOrigin map[string]string
+	// prevOrigin holds the origins tracked before eventOrigin last filled up
+	prevOrigin map[string]string
+
+	syncLock sync.Mutex
+	synced   map[string]map[string]*calendar.Event
+	// syncedAt is when the last successful sync of each calendar started
+	syncedAt map[string]time.Time
+	index    *search.Index
+	indexed  []*calendar.Event
 }
 
-func NewCalendar(ctx context.Context, googleCfg *oauth2.Config, refreshToken string) (*Calendar, error) {
+func NewCalendar(ctx context.Context, googleCfg *oauth2.Config, refreshToken string, cfg Config) (*Calendar, error) {
 	client := googleCfg.Client(context.Background(), &oauth2.Token{
 		TokenType:    "Bearer",
 		RefreshToken: refreshToken,
@@ -36,34 +54,48 @@ func NewCalendar(ctx context.Context, googleCfg *oauth2.Config, refreshToken str
 	if err != nil {
 		return nil, err
 	}
-	return &Calendar{Service: cal}, nil
+	return &Calendar{
+		Service:     cal,
+		cfg:         cfg,
+		breakers:    make(map[string]*breaker),
+		eventOrigin: make(map[string]string),
+		synced:      make(map[string]map[string]*calendar.Event),
+		syncedAt:    make(map[string]time.Time),
+	}, nil
 }
 
-func (cal *Calendar) List() []string {
-	cal.Refresh()
+func (cal *Calendar) List(ctx context.Context) []string {
+	cal.Refresh(ctx)
 	cal.RLock()
 	defer cal.RUnlock()
 
 	var names []string
-	for _, c := range cal.subCalendars {
-		if c.Primary || strings.HasPrefix(c.Summary, "http") {
+	for _, c := range cal.selectedCalendars() {
+		// subscribed feeds are titled with their url unless they are given a name in the config
+		name := cal.cfg.name(c)
+		if c.Primary || strings.HasPrefix(name, "http") {
 			continue
 		}
-		names = append(names, c.Summary)
+		names = append(names, name)
 	}
 
 	return names
 }
 
-func (cal *Calendar) Refresh() {
+func (cal *Calendar) Refresh(ctx context.Context) {
 	cal.Lock()
 	defer cal.Unlock()
 	// TODO: adjust cache interval
 	if time.Now().After(cal.lastRefresh.Add(time.Minute * 5)) {
-		updated, err := cal.CalendarList.List().IfNoneMatch(cal.calListEtag).Do()
+		var updated *calendar.CalendarList
+		err := retry(ctx, func(ctx context.Context) (err error) {
+			updated, err = cal.CalendarList.List().IfNoneMatch(cal.calListEtag).Context(ctx).Do()
+			return err
+		})
 		if err != nil {
 			if !googleapi.IsNotModified(err) {
 				logrus.Error("failed to refresh calendar list", err)
+				return
 			}
 		} else {
 			if updated.NextPageToken != "" {
@@ -72,108 +104,198 @@ func (cal *Calendar) Refresh() {
 			cal.subCalendars = updated.Items
 			cal.calListEtag = updated.Etag
 		}
+		cal.lastRefresh = time.Now()
 	}
 }
 
-func (cal *Calendar) CalendarIDs() []string {
-	cal.Refresh()
+func (cal *Calendar) CalendarIDs(ctx context.Context) []string {
+	cal.Refresh(ctx)
 	cal.RLock()
 	defer cal.RUnlock()
 
 	var calIds []string
-	for _, c := range cal.subCalendars {
+	for _, c := range cal.selectedCalendars() {
 		calIds = append(calIds, c.Id)
 	}
 
 	return calIds
 }
 
-func (cal *Calendar) CalendarIDsMatching(query string) []string {
-	cal.Refresh()
+func (cal *Calendar) CalendarIDsMatching(ctx context.Context, query string) []string {
+	cal.Refresh(ctx)
 	cal.RLock()
 	defer cal.RUnlock()
 
 	var calIds []string
-	for _, c := range cal.subCalendars {
-		if !c.Primary && (util.ContainsFold(c.SummaryOverride, query) || util.ContainsFold(c.Summary, query)) {
+	for _, c := range cal.selectedCalendars() {
+		if !c.Primary && cal.cfg.matches(c, query) {
 			calIds = append(calIds, c.Id)
 		}
 	}
 	return calIds
 }
 
-func (cal *Calendar) OngoingEvents() ([]*calendar.Event, error) {
-	startTime := time.Now().AddDate(0, 0, -10).Format(time.RFC3339)
-	endTime := time.Now().Format(time.RFC3339)
-	candidates, err := cal.QueryCalendars("", func(c *calendar.EventsListCall) { c.TimeMax(endTime).TimeMin(startTime) })
-	if err != nil {
+// OngoingEvents returns all events that overlap the current time, sorted by starting time
+func (cal *Calendar) OngoingEvents(ctx context.Context) ([]*calendar.Event, error) {
+	now := time.Now()
+	// google matches timeMin against the end and timeMax against the start of events,
+	// so this selects everything that started before and ends after now. Recurring
+	// all-day series only return the instance covering today.
+	startTime := now.Format(time.RFC3339)
+	endTime := now.Add(time.Second).Format(time.RFC3339)
+	candidates, err := cal.QueryCalendars(ctx, "", func(c *calendar.EventsListCall) { c.TimeMin(startTime).TimeMax(endTime) })
+	if err != nil && !IsPartial(err) {
 		return nil, fmt.Errorf("failed to get ongoing events: %w", err)
 	}
 
 	var results []*calendar.Event
 	for _, event := range candidates {
-		if EndTime(event).After(time.Now()) {
+		if EventSpan(event).Ongoing(now) {
+			results = append(results, event)
+		}
+	}
+
+	return results, err
+}
+
+// EndingWithin returns the ongoing events that end within d, sorted by end time
+func (cal *Calendar) EndingWithin(ctx context.Context, d time.Duration) ([]*calendar.Event, error) {
+	ongoing, err := cal.OngoingEvents(ctx)
+	if err != nil && !IsPartial(err) {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(d)
+	var results []*calendar.Event
+	for _, event := range ongoing {
+		if !EndTime(event).After(deadline) {
 			results = append(results, event)
 		}
 	}
+	sort.SliceStable(results, func(i, j int) bool { return EndTime(results[i]).Before(EndTime(results[j])) })
 
-	return results, nil
+	return results, err
 }
 
-func (cal *Calendar) multiFast(calendars []string, mods ...func(c *calendar.EventsListCall)) ([]*calendar.Event, error) {
-	resChan := make(chan []*calendar.Event)
-	errChan := make(chan error)
+// maxListPages bounds the result pages fetched from one calendar for a range query
+const maxListPages = 10
+
+// multiFast queries all calendars concurrently and returns the events of each calendar
+// in the order the calendars were given in, following up to pages result pages each
+func (cal *Calendar) multiFast(ctx context.Context, calendars []string, pages int, mods ...func(c *calendar.EventsListCall)) ([][]*calendar.Event, error) {
+	type result struct {
+		index  int
+		id     string
+		events []*calendar.Event
+		err    error
+	}
+	resChan := make(chan result, len(calendars))
 
-	for _, id := range calendars {
-		id := id
+	for i, id := range calendars {
+		i, id := i, id
 		go func() {
 			q := cal.Events.List(id)
 			for _, mod := range mods {
 				mod(q)
 			}
-			events, err := cal.executeListCall(q, id)
-			if err != nil {
-				errChan <- err
-			} else {
-				resChan <- events
-			}
+			events, err := cal.executeListCall(ctx, q, id, pages)
+			resChan <- result{index: i, id: id, events: events, err: err}
 		}()
 	}
 
 	var resErr error
-	var res []*calendar.Event
+	partial := &PartialError{Failed: make(map[string]error)}
+	res := make([][]*calendar.Event, len(calendars))
 	for i := 0; i < len(calendars); i++ {
-		select {
-		case err := <-errChan:
-			resErr = multierror.Append(resErr, err)
-			logrus.Error(err)
-		case events := <-resChan:
-			res = append(res, events...)
+		r := <-resChan
+		if r.err != nil {
+			resErr = multierror.Append(resErr, r.err)
+			partial.Failed[r.id] = r.err
+			logrus.Error(r.err)
+			continue
 		}
+		res[r.index] = r.events
 	}
 
-	// return an error if all queries fail, otherwise return the partial result with no error
-	if len(res) == 0 && resErr != nil {
+	// return an error if all queries fail, otherwise return the partial result
+	// and the calendars that failed
+	if len(partial.Failed) > 0 && len(partial.Failed) == len(calendars) {
 		return nil, resErr
 	}
+	if len(partial.Failed) > 0 {
+		return res, partial
+	}
 
 	return res, nil
 }
 
 // returns a list of all events that are ongoing or happenign in the future, sorted by starting time
-func (cal *Calendar) Query(query string, amount int64) ([]*calendar.Event, error) {
-	results, err := cal.QueryCalendars(query, func(c *calendar.EventsListCall) { c.MaxResults(amount).TimeMin(time.Now().Format(time.RFC3339)) })
-	if err != nil {
+func (cal *Calendar) Query(ctx context.Context, query string, amount int64) ([]*calendar.Event, error) {
+	streams, err := cal.queryStreams(ctx, query, 1, func(c *calendar.EventsListCall) { c.MaxResults(amount).TimeMin(time.Now().Format(time.RFC3339)) })
+	if err != nil && !IsPartial(err) {
 		return nil, err
 	}
 
-	sort.Slice(results, func(i, j int) bool { return StartTime(results[i]).After(StartTime(results[j])) })
-	if len(results) > int(amount) {
-		return results[:int(amount)], nil
+	return mergeEvents(streams, int(amount)), err
+}
+
+// Between returns all matching events that overlap the range from to, sorted by starting time
+func (cal *Calendar) Between(ctx context.Context, query string, from, to time.Time) ([]*calendar.Event, error) {
+	return cal.QueryCalendars(ctx, query, func(c *calendar.EventsListCall) {
+		// large pages, so a daily series over a year takes a single request
+		c.TimeMin(from.Format(time.RFC3339)).TimeMax(to.Format(time.RFC3339)).MaxResults(2500)
+	})
+}
+
+// lastWindows are searched one after another by Last, recent events are found with small queries
+var lastWindows = []time.Duration{time.Hour * 24 * 7, time.Hour * 24 * 30, time.Hour * 24 * 365}
+
+// Past returns the matching events that ended within window before now, sorted by starting time
+func (cal *Calendar) Past(ctx context.Context, query string, window time.Duration) ([]*calendar.Event, error) {
+	now := time.Now()
+	candidates, err := cal.Between(ctx, query, now.Add(-window), now)
+	if err != nil && !IsPartial(err) {
+		return nil, err
 	}
-	return results, nil
+
+	var results []*calendar.Event
+	for _, event := range candidates {
+		if !EndTime(event).After(now) {
+			results = append(results, event)
+		}
+	}
+
+	return results, err
 }
 
+// Last returns the most recent matching event that already ended, or nil if there was none within a year
+func (cal *Calendar) Last(ctx context.Context, query string) (*calendar.Event, error) {
+	var partial error
+	for _, window := range lastWindows {
+		events, err := cal.Past(ctx, query, window)
+		if err != nil && !IsPartial(err) {
+			return nil, err
+		}
+		if err != nil {
+			partial = err
+		}
+		if len(events) > 0 {
+			last := events[0]
+			for _, e := range events[1:] {
+				if !StartTime(e).Before(StartTime(last)) {
+					last = e
+				}
+			}
+			return last, partial
+		}
+	}
+
+	return nil, partial
+}
+
+// DefaultLocation is used for events whose own and calendar timezone are unknown.
+var DefaultLocation = time.UTC
+
 func EndTime(e *calendar.Event) time.Time {
 	t, err := toTime(e.End)
 	if err != nil {
@@ -192,48 +314,169 @@ func StartTime(e *calendar.Event) time.Time {
 	return t
 }
 
+// AllDay returns true if the event is not bound to a time of day
+func AllDay(e *calendar.Event) bool {
+	return e.Start != nil && e.Start.DateTime == "" && e.Start.Date != ""
+}
+
+// Span describes when an event takes place, in the event's own timezone.
+// For all-day events End is exclusive, like in the google calendar api:
+// an event on a single day ends at midnight of the following day.
+type Span struct {
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+}
+
+func EventSpan(e *calendar.Event) Span {
+	return Span{
+		Start:  StartTime(e),
+		End:    EndTime(e),
+		AllDay: AllDay(e),
+	}
+}
+
+// Ongoing returns true if t lies within the span
+func (s Span) Ongoing(t time.Time) bool {
+	return !t.Before(s.Start) && t.Before(s.End)
+}
+
+// LastDay returns the midnight of the last day the span covers
+func (s Span) LastDay() time.Time {
+	last := s.End
+	if s.AllDay || last.Equal(Midnight(last)) {
+		last = last.Add(-time.Nanosecond)
+	}
+	if last.Before(s.Start) {
+		last = s.Start
+	}
+
+	return Midnight(last)
+}
+
+// MultiDay returns true if the span covers more than one calendar day
+func (s Span) MultiDay() bool {
+	return s.LastDay().After(Midnight(s.Start))
+}
+
+// Midnight returns the start of the day t is in, in t's location
+func Midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// DaysBetween returns the number of calendar days from a to b, each measured in its own location
+func DaysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	from := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	to := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+
+	return int(to.Sub(from).Hours() / 24)
+}
+
+var locations sync.Map
+
+// location resolves the timezone of an event time, falling back to DefaultLocation
+func location(calTime *calendar.EventDateTime) *time.Location {
+	if calTime.TimeZone == "" {
+		return DefaultLocation
+	}
+	if loc, ok := locations.Load(calTime.TimeZone); ok {
+		return loc.(*time.Location)
+	}
+
+	loc, err := time.LoadLocation(calTime.TimeZone)
+	if err != nil {
+		logrus.Errorf("unknown timezone %q: %s", calTime.TimeZone, err)
+		return DefaultLocation
+	}
+	locations.Store(calTime.TimeZone, loc)
+
+	return loc
+}
+
 func toTime(calTime *calendar.EventDateTime) (time.Time, error) {
-	var t time.Time
-	var err error
-	if calTime.DateTime != "" {
-		t, err = time.Parse(time.RFC3339, calTime.DateTime)
-	} else {
-		t, err = time.Parse("2006-01-02", calTime.Date)
+	if calTime == nil {
+		return time.Time{}, fmt.Errorf("missing time")
+	}
+
+	loc := location(calTime)
+	if calTime.DateTime == "" {
+		return time.ParseInLocation("2006-01-02", calTime.Date, loc)
 	}
 
-	return t, err
+	t, err := time.Parse(time.RFC3339, calTime.DateTime)
+	if err != nil {
+		// floating times carry no offset and happen wherever the event is
+		var ferr error
+		t, ferr = time.ParseInLocation("2006-01-02T15:04:05", calTime.DateTime, loc)
+		if ferr != nil {
+			return t, err
+		}
+	}
+
+	return t.In(loc), nil
 }
 
-func (cal *Calendar) AddEvent(creator, title, description string, start time.Time, duration time.Duration) error {
-	_, err := cal.Events.Insert("primary", &calendar.Event{
+// CreatorProperty is the private extended property holding the chatter that created an event
+const CreatorProperty = "whenisCreator"
+
+// AddEvent creates an event, it repeats if recurrence is set
+func (cal *Calendar) AddEvent(ctx context.Context, calID, creator, title, description string, start time.Time, duration time.Duration, recurrence *timeparse.Recurrence) (*calendar.Event, error) {
+	event := &calendar.Event{
 		Summary:     title,
 		Description: description,
 		Creator: &calendar.EventCreator{
 			DisplayName: creator,
 		},
 		Location: "strims.gg",
-		Start: &calendar.EventDateTime{
-			DateTime: start.Format(time.RFC3339),
-		},
-		End: &calendar.EventDateTime{
-			DateTime: start.Add(duration).Format(time.RFC3339),
+		Start:    EventTime(start),
+		End:      EventTime(start.Add(duration)),
+		// the creator field is set by google, so the chatter is kept separately
+		ExtendedProperties: &calendar.EventExtendedProperties{
+			Private: map[string]string{CreatorProperty: creator},
 		},
-	}).Do()
+	}
+	if recurrence != nil {
+		event.Recurrence = []string{rrule(recurrence)}
+	}
+
+	// inserts are not idempotent, so they are not retried
+	var created *calendar.Event
+	err := cal.callOnce(ctx, calID, func(ctx context.Context) (err error) {
+		created, err = cal.Events.Insert(calID, event).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	cal.track(calID, cal.calendarTimezone(calID), created)
 
-	return err
+	return created, nil
 }
 
-func (cal *Calendar) QueryCalendarTitles(query string) (*calendar.Event, error) {
+func (cal *Calendar) QueryCalendarTitles(ctx context.Context, query string) (*calendar.Event, error) {
 	var earliest *calendar.Event
 
-	for _, id := range cal.CalendarIDsMatching(query) {
-		event, err := cal.QueryCalendarSingle("", id, func(c *calendar.EventsListCall) { c.TimeMin(time.Now().Format(time.RFC3339)) })
+	ids := cal.CalendarIDsMatching(ctx, query)
+	partial := &PartialError{Failed: make(map[string]error)}
+	for _, id := range ids {
+		event, err := cal.QueryCalendarSingle(ctx, "", id, func(c *calendar.EventsListCall) { c.TimeMin(time.Now().Format(time.RFC3339)) })
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch first event from calendar: %w", err)
+			partial.Failed[id] = err
+			continue
 		}
 		earliest = FirstEvent(earliest, event)
 	}
 
+	if len(partial.Failed) > 0 {
+		if len(partial.Failed) == len(ids) {
+			return nil, fmt.Errorf("failed to fetch first event from calendar: %w", partial)
+		}
+		return earliest, partial
+	}
+
 	return earliest, nil
 }
 
@@ -244,13 +487,8 @@ func FirstEvent(events ...*calendar.Event) *calendar.Event {
 		if event == nil {
 			continue
 		}
-		if earliest == nil {
-			earliest = event
-			continue
-		}
-
 		eventTime := StartTime(event)
-		if eventTime.Before(earliestTime) {
+		if earliest == nil || eventTime.Before(earliestTime) {
 			earliestTime = eventTime
 			earliest = event
 		}
@@ -259,15 +497,45 @@ func FirstEvent(events ...*calendar.Event) *calendar.Event {
 	return earliest
 }
 
-func (cal *Calendar) executeListCall(query *calendar.EventsListCall, calID string) ([]*calendar.Event, error) {
-	e, err := query.Do()
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch events for calendar %q: %w", calID, err)
+// executeListCall runs query and follows up to pages result pages
+func (cal *Calendar) executeListCall(ctx context.Context, query *calendar.EventsListCall, calID string, pages int) ([]*calendar.Event, error) {
+	var items []*calendar.Event
+	for page := 0; page < pages; page++ {
+		var e *calendar.Events
+		err := cal.call(ctx, calID, func(ctx context.Context) (err error) {
+			e, err = query.Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch events for calendar %q: %w", calID, err)
+		}
+
+		cal.track(calID, e.TimeZone, e.Items...)
+		items = append(items, e.Items...)
+		if e.NextPageToken == "" {
+			return items, nil
+		}
+		query.PageToken(e.NextPageToken)
+	}
+	if pages > 1 {
+		logrus.Warnf("calendar %q has more than %d pages of results, skipping the rest", calID, pages)
 	}
-	return e.Items, nil
+
+	return items, nil
+}
+
+// QueryCalendars returns all matching events of all calendars, sorted by starting time.
+// It is meant for queries bounded by a time range, as it follows the result pages.
+func (cal *Calendar) QueryCalendars(ctx context.Context, query string, mods ...func(c *calendar.EventsListCall)) ([]*calendar.Event, error) {
+	streams, err := cal.queryStreams(ctx, query, maxListPages, mods...)
+	if err != nil && !IsPartial(err) {
+		return nil, err
+	}
+
+	return mergeEvents(streams, 0), err
 }
 
-func (cal *Calendar) QueryCalendars(query string, mods ...func(c *calendar.EventsListCall)) ([]*calendar.Event, error) {
+func (cal *Calendar) queryStreams(ctx context.Context, query string, pages int, mods ...func(c *calendar.EventsListCall)) ([][]*calendar.Event, error) {
 	mod := func(c *calendar.EventsListCall) {
 		c.ShowDeleted(false).
 			SingleEvents(true).
@@ -277,10 +545,10 @@ func (cal *Calendar) QueryCalendars(query string, mods ...func(c *calendar.Event
 		}
 	}
 
-	return cal.multiFast(cal.CalendarIDs(), append(mods, mod)...)
+	return cal.multiFast(ctx, cal.CalendarIDs(ctx), pages, append(mods, mod)...)
 }
 
-func (cal *Calendar) QueryCalendarSingle(query, calID string, mods ...func(c *calendar.EventsListCall)) (*calendar.Event, error) {
+func (cal *Calendar) QueryCalendarSingle(ctx context.Context, query, calID string, mods ...func(c *calendar.EventsListCall)) (*calendar.Event, error) {
 	q := cal.Events.List(calID).ShowDeleted(false).
 		SingleEvents(true).
 		OrderBy("startTime").
@@ -290,7 +558,7 @@ func (cal *Calendar) QueryCalendarSingle(query, calID string, mods ...func(c *ca
 		mod(q)
 	}
 
-	events, err := cal.executeListCall(q, calID)
+	events, err := cal.executeListCall(ctx, q, calID, 1)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,158 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/MemeLabs/whenis/pkg/util"
+	"google.golang.org/api/calendar/v3"
+)
+
+// Config selects the calendars whenis searches and adds events to. Calendars are
+// referenced by id, summary or display name, case insensitively.
+type Config struct {
+	// Include limits searches to these calendars, if empty all calendars are searched
+	Include []string `json:"include"`
+	// Exclude removes calendars from searches
+	Exclude []string `json:"exclude"`
+	// Calendars holds display names and aliases, keyed by calendar id
+	Calendars map[string]CalendarConfig `json:"calendars"`
+	// Target is the calendar events created through the bot are added to, "primary" if empty
+	Target string `json:"target"`
+	// UserTargets overrides Target for single chatters
+	UserTargets map[string]string `json:"userTargets"`
+}
+
+type CalendarConfig struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// name returns the name a calendar is shown as
+func (cfg Config) name(c *calendar.CalendarListEntry) string {
+	if n := cfg.Calendars[c.Id].Name; n != "" {
+		return n
+	}
+	if c.SummaryOverride != "" {
+		return c.SummaryOverride
+	}
+
+	return c.Summary
+}
+
+// refersTo returns true if ref names the calendar by its id, summary, display name or one of its aliases
+func (cfg Config) refersTo(c *calendar.CalendarListEntry, ref string) bool {
+	if strings.EqualFold(c.Id, ref) || strings.EqualFold(c.Summary, ref) ||
+		strings.EqualFold(c.SummaryOverride, ref) || strings.EqualFold(cfg.name(c), ref) {
+		return true
+	}
+	for _, alias := range cfg.Calendars[c.Id].Aliases {
+		if strings.EqualFold(alias, ref) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches returns true if query is part of the calendar's names or equals one of its aliases
+func (cfg Config) matches(c *calendar.CalendarListEntry, query string) bool {
+	if util.ContainsFold(c.SummaryOverride, query) || util.ContainsFold(c.Summary, query) ||
+		util.ContainsFold(cfg.name(c), query) {
+		return true
+	}
+	for _, alias := range cfg.Calendars[c.Id].Aliases {
+		if strings.EqualFold(alias, query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selected returns true if the calendar should be searched
+func (cfg Config) selected(c *calendar.CalendarListEntry) bool {
+	for _, ref := range cfg.Exclude {
+		if cfg.refersTo(c, ref) {
+			return false
+		}
+	}
+	if len(cfg.Include) == 0 {
+		return true
+	}
+	for _, ref := range cfg.Include {
+		if cfg.refersTo(c, ref) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selectedCalendars returns the calendars that should be searched, the caller must hold the read lock
+func (cal *Calendar) selectedCalendars() []*calendar.CalendarListEntry {
+	var selected []*calendar.CalendarListEntry
+	for _, c := range cal.subCalendars {
+		if cal.cfg.selected(c) {
+			selected = append(selected, c)
+		}
+	}
+
+	return selected
+}
+
+// CalendarName returns the display name of a calendar, or its id if it is unknown
+func (cal *Calendar) CalendarName(id string) string {
+	cal.RLock()
+	defer cal.RUnlock()
+
+	for _, c := range cal.subCalendars {
+		if c.Id == id {
+			return cal.cfg.name(c)
+		}
+	}
+
+	return id
+}
+
+// ResolveCalendar returns the id of the calendar ref refers to
+func (cal *Calendar) ResolveCalendar(ctx context.Context, ref string) (string, error) {
+	if ref == "primary" {
+		return ref, nil
+	}
+
+	cal.Refresh(ctx)
+	cal.RLock()
+	defer cal.RUnlock()
+
+	for _, c := range cal.subCalendars {
+		if cal.cfg.refersTo(c, ref) {
+			return c.Id, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown calendar %q", ref)
+}
+
+// Target returns the calendar an event should be added to. An explicit override
+// wins over the chatter's configured calendar, which wins over the default target.
+func (cal *Calendar) Target(ctx context.Context, override, creator string) (string, error) {
+	ref := override
+	if ref == "" {
+		for nick, target := range cal.cfg.UserTargets {
+			if strings.EqualFold(nick, creator) {
+				ref = target
+				break
+			}
+		}
+	}
+	if ref == "" {
+		ref = cal.cfg.Target
+	}
+	if ref == "" {
+		return "primary", nil
+	}
+
+	return cal.ResolveCalendar(ctx, ref)
+}
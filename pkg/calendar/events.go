@@ -0,0 +1,151 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrEventNotFound is returned for events that were deleted or never existed
+var ErrEventNotFound = errors.New("event not found")
+
+// maxTracked is the amount of event origins remembered before the oldest are dropped
+const maxTracked = 20000
+
+// track remembers which calendar events came from and fills in the timezone of
+// events without their own with the one of their calendar
+func (cal *Calendar) track(calID, timezone string, events ...*calendar.Event) {
+	cal.eventLock.Lock()
+	defer cal.eventLock.Unlock()
+
+	for _, event := range events {
+		// origins are kept in two generations, the older one is dropped once the newer fills up
+		if _, ok := cal.eventOrigin[event.Id]; !ok && len(cal.eventOrigin) >= maxTracked {
+			cal.prevOrigin = cal.eventOrigin
+			cal.eventOrigin = make(map[string]string)
+		}
+		cal.eventOrigin[event.Id] = calID
+		for _, t := range []*calendar.EventDateTime{event.Start, event.End} {
+			if t != nil && t.TimeZone == "" {
+				t.TimeZone = timezone
+			}
+		}
+	}
+}
+
+// CalendarOf returns the id of the calendar an event was fetched from
+func (cal *Calendar) CalendarOf(e *calendar.Event) string {
+	cal.eventLock.Lock()
+	defer cal.eventLock.Unlock()
+
+	if calID, ok := cal.eventOrigin[e.Id]; ok {
+		return calID
+	}
+
+	return cal.prevOrigin[e.Id]
+}
+
+// calendarTimezone returns the timezone of a calendar, if it is known
+func (cal *Calendar) calendarTimezone(calID string) string {
+	cal.RLock()
+	defer cal.RUnlock()
+
+	for _, c := range cal.subCalendars {
+		if c.Id == calID {
+			return c.TimeZone
+		}
+	}
+
+	return ""
+}
+
+// Event fetches a single event, cancelled events are returned with their status set
+func (cal *Calendar) Event(ctx context.Context, calID, eventID string) (*calendar.Event, error) {
+	var event *calendar.Event
+	err := cal.call(ctx, calID, func(ctx context.Context) (err error) {
+		event, err = cal.Events.Get(calID, eventID).Context(ctx).Do()
+		return err
+	})
+	if isNotFound(err) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch event %q: %w", eventID, err)
+	}
+	cal.track(calID, cal.calendarTimezone(calID), event)
+
+	return event, nil
+}
+
+// NextInstance returns the first occurrence of a recurring event that starts after t, or nil if there is none
+func (cal *Calendar) NextInstance(ctx context.Context, calID, recurringID string, t time.Time) (*calendar.Event, error) {
+	var instances *calendar.Events
+	err := cal.call(ctx, calID, func(ctx context.Context) (err error) {
+		// timeMin is matched against the end of instances, so ongoing ones show up as well
+		instances, err = cal.Events.Instances(calID, recurringID).
+			ShowDeleted(false).
+			TimeMin(t.Format(time.RFC3339)).
+			MaxResults(5).
+			Context(ctx).
+			Do()
+		return err
+	})
+	if isNotFound(err) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instances of %q: %w", recurringID, err)
+	}
+	cal.track(calID, instances.TimeZone, instances.Items...)
+
+	for _, instance := range instances.Items {
+		if StartTime(instance).After(t) {
+			return instance, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// UpdateEvent changes the fields of an event that are set in patch
+func (cal *Calendar) UpdateEvent(ctx context.Context, calID, eventID string, patch *calendar.Event) (*calendar.Event, error) {
+	var updated *calendar.Event
+	err := cal.call(ctx, calID, func(ctx context.Context) (err error) {
+		updated, err = cal.Events.Patch(calID, eventID, patch).Context(ctx).Do()
+		return err
+	})
+	if isNotFound(err) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event %q: %w", eventID, err)
+	}
+	cal.track(calID, cal.calendarTimezone(calID), updated)
+
+	return updated, nil
+}
+
+// DeleteEvent removes an event, deleting a recurring event removes all of its occurrences
+func (cal *Calendar) DeleteEvent(ctx context.Context, calID, eventID string) error {
+	err := cal.call(ctx, calID, func(ctx context.Context) error {
+		return cal.Events.Delete(calID, eventID).Context(ctx).Do()
+	})
+	if isNotFound(err) {
+		return ErrEventNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete event %q: %w", eventID, err)
+	}
+
+	return nil
+}
+
+func isNotFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && (gerr.Code == http.StatusNotFound || gerr.Code == http.StatusGone)
+}
@@ -0,0 +1,197 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/search"
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"github.com/MemeLabs/whenis/pkg/util"
+	"google.golang.org/api/calendar/v3"
+)
+
+// filterScanLimit is how many events per calendar are fetched when results are filtered locally
+const filterScanLimit = 250
+
+var filterKeys = map[string]bool{"cal": true, "calendar": true, "after": true, "before": true, "title": true, "tag": true}
+
+// Filter narrows down a search. It is written as keys in front of the free text,
+// e.g. `cal:F1 after:2026-11-01 before:2026-12-01 title:"race" tag:sports`.
+type Filter struct {
+	// Text is searched in titles and descriptions by the calendar api
+	Text string
+	// Calendars limits the search to these calendars, referenced by name, alias or id
+	Calendars []string
+	// After and Before limit the start of events
+	After  time.Time
+	Before time.Time
+	// Title has to be part of the title
+	Title string
+	// Tags have to be words of the description or title
+	Tags []string
+}
+
+// ParseFilter reads the keys of a query, dates are read in loc. It returns false if
+// the query has no keys and is plain text.
+func ParseFilter(query string, now time.Time, loc *time.Location) (Filter, bool, error) {
+	var f Filter
+	var text []string
+	structured := false
+	for _, arg := range util.SplitArgs(query) {
+		i := strings.Index(arg, ":")
+		if i <= 0 {
+			text = append(text, arg)
+			continue
+		}
+		key, value := strings.ToLower(arg[:i]), strings.TrimSpace(arg[i+1:])
+		if value == "" && filterKeys[key] {
+			return f, true, fmt.Errorf("%s: needs a value", key)
+		}
+		var err error
+		switch key {
+		case "cal", "calendar":
+			f.Calendars = append(f.Calendars, value)
+		case "after":
+			f.After, err = parseFilterTime(value, now, loc)
+		case "before":
+			f.Before, err = parseFilterTime(value, now, loc)
+		case "title":
+			f.Title = value
+		case "tag":
+			f.Tags = append(f.Tags, value)
+		default:
+			// not a key, e.g. "Re:Zero"
+			text = append(text, arg)
+			continue
+		}
+		if err != nil {
+			return f, true, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		structured = true
+	}
+	f.Text = strings.Join(text, " ")
+
+	return f, structured, nil
+}
+
+// parseFilterTime accepts dates, which mean the start of the day, and everything timeparse understands
+func parseFilterTime(value string, now time.Time, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, nil
+	}
+
+	return timeparse.Parse(value, now, loc)
+}
+
+// local returns true if parts of the filter can't be run by the calendar api
+func (f Filter) local() bool {
+	return f.Title != "" || len(f.Tags) > 0 || !f.After.IsZero()
+}
+
+// matches applies the parts of the filter that the calendar api can't
+func (f Filter) matches(e *calendar.Event) bool {
+	if f.Title != "" && !strings.Contains(search.Fold(e.Summary), search.Fold(f.Title)) {
+		return false
+	}
+	if !f.After.IsZero() && StartTime(e).Before(f.After) {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		title, description := search.Tokenize(e.Summary), search.Tokenize(e.Description)
+		for _, tag := range f.Tags {
+			words := search.Tokenize(tag)
+			if !containsPhrase(title, words) && !containsPhrase(description, words) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// containsPhrase returns true if phrase occurs in words as consecutive words
+func containsPhrase(words, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, w := range phrase {
+			if words[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Find returns up to limit events that match the filter, sorted by starting time.
+// Text, calendars and time range are run by the calendar api, the rest is filtered locally.
+func (cal *Calendar) Find(ctx context.Context, f Filter, limit int) ([]*calendar.Event, error) {
+	ids := cal.CalendarIDs(ctx)
+	if len(f.Calendars) > 0 {
+		ids = nil
+		seen := make(map[string]bool)
+		for _, ref := range f.Calendars {
+			matched := cal.CalendarIDsMatching(ctx, ref)
+			if id, err := cal.ResolveCalendar(ctx, ref); err == nil {
+				matched = append(matched, id)
+			}
+			if len(matched) == 0 {
+				return nil, fmt.Errorf("unknown calendar %q", ref)
+			}
+			for _, id := range matched {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	// the api searches words anywhere, so title and tags narrow the query as well
+	q := strings.TrimSpace(strings.Join(append([]string{f.Text, f.Title}, f.Tags...), " "))
+	from := time.Now()
+	if !f.After.IsZero() {
+		from = f.After
+	}
+	perCalendar := int64(limit)
+	if f.local() {
+		perCalendar = filterScanLimit
+	}
+	streams, err := cal.multiFast(ctx, ids, 1, func(c *calendar.EventsListCall) {
+		c.ShowDeleted(false).
+			SingleEvents(true).
+			OrderBy("startTime").
+			TimeMin(from.Format(time.RFC3339)).
+			MaxResults(perCalendar)
+		if !f.Before.IsZero() {
+			c.TimeMax(f.Before.Format(time.RFC3339))
+		}
+		if q != "" {
+			c.Q(q)
+		}
+	})
+	if err != nil && !IsPartial(err) {
+		return nil, err
+	}
+
+	for i, stream := range streams {
+		filtered := stream[:0]
+		for _, e := range stream {
+			if f.matches(e) {
+				filtered = append(filtered, e)
+			}
+		}
+		streams[i] = filtered
+	}
+
+	return mergeEvents(streams, limit), err
+}
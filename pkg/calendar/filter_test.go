@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestParseFilter(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a wednesday afternoon
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		query      string
+		want       Filter
+		structured bool
+		ok         bool
+	}{
+		{"formula 1", Filter{Text: "formula 1"}, false, true},
+		// a colon does not make a key
+		{"Re:Zero", Filter{Text: "Re:Zero"}, false, true},
+		{"watch Re:Zero tag:anime", Filter{Text: "watch Re:Zero", Tags: []string{"anime"}}, true, true},
+		{"cal:F1 qualifying", Filter{Text: "qualifying", Calendars: []string{"F1"}}, true, true},
+		{"CAL:F1 calendar:motogp", Filter{Calendars: []string{"F1", "motogp"}}, true, true},
+		{`title:"movie night" popcorn`, Filter{Text: "popcorn", Title: "movie night"}, true, true},
+		{`tag:"grand prix" tag:sports`, Filter{Tags: []string{"grand prix", "sports"}}, true, true},
+		{"after:2026-11-01", Filter{After: time.Date(2026, 11, 1, 0, 0, 0, 0, berlin)}, true, true},
+		{`after:"friday 20:00"`, Filter{After: time.Date(2026, 10, 16, 20, 0, 0, 0, berlin)}, true, true},
+		{"before:2026-12-01 race", Filter{Text: "race", Before: time.Date(2026, 12, 1, 0, 0, 0, 0, berlin)}, true, true},
+		{"after:", Filter{}, true, false},
+		{`title:""`, Filter{}, true, false},
+		{"after:someday", Filter{}, true, false},
+		{"", Filter{}, false, true},
+	}
+	for _, tt := range tests {
+		got, structured, err := ParseFilter(tt.query, now, berlin)
+		if (err == nil) != tt.ok || structured != tt.structured {
+			t.Errorf("ParseFilter(%q) = %v, %v, want structured %v and ok %v", tt.query, structured, err, tt.structured, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if !got.After.Equal(tt.want.After) || !got.Before.Equal(tt.want.Before) {
+			t.Errorf("ParseFilter(%q) range = %v - %v, want %v - %v", tt.query, got.After, got.Before, tt.want.After, tt.want.Before)
+		}
+		got.After, got.Before, tt.want.After, tt.want.Before = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFilter(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	start := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	event := &calendar.Event{
+		Summary:     "Formula 1: Grand Prix of São Paulo",
+		Description: "race day, bring snacks",
+		Start:       &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"title", Filter{Title: "grand prix"}, true},
+		{"folded title", Filter{Title: "SAO PAULO"}, true},
+		{"other title", Filter{Title: "motogp"}, false},
+		{"tag in title", Filter{Tags: []string{"formula"}}, true},
+		{"tag in description", Filter{Tags: []string{"snacks"}}, true},
+		// tags of several words match them in order
+		{"multi-word tag", Filter{Tags: []string{"race day"}}, true},
+		{"multi-word tag in title", Filter{Tags: []string{"grand prix"}}, true},
+		{"multi-word tag out of order", Filter{Tags: []string{"day race"}}, false},
+		{"multi-word tag across fields", Filter{Tags: []string{"paulo race"}}, false},
+		{"blank tag", Filter{Tags: []string{" "}}, false},
+		{"tags", Filter{Tags: []string{"race", "snacks"}}, true},
+		{"missing tag", Filter{Tags: []string{"race", "movie"}}, false},
+		{"partial tag", Filter{Tags: []string{"snack"}}, false},
+		{"after", Filter{After: start.Add(-time.Hour)}, true},
+		{"at start", Filter{After: start}, true},
+		{"before start", Filter{After: start.Add(time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matches(event); got != tt.want {
+			t.Errorf("%s: matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
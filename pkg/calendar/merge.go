@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"container/heap"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// mergeEvents merges lists of events that are each sorted by start time into one
+// sorted list of at most limit events, or all of them if limit is not positive.
+// Events with the same start time keep the order of the lists they come from, and
+// events that show up in several lists (e.g. when calendars share an event) are
+// only returned once.
+func mergeEvents(streams [][]*calendar.Event, limit int) []*calendar.Event {
+	h := make(cursorHeap, 0, len(streams))
+	for i, events := range streams {
+		if len(events) > 0 {
+			h = append(h, &cursor{stream: i, events: events, start: StartTime(events[0])})
+		}
+	}
+	heap.Init(&h)
+
+	seen := make(map[string]struct{})
+	var merged []*calendar.Event
+	for h.Len() > 0 && (limit <= 0 || len(merged) < limit) {
+		c := h[0]
+		event := c.events[c.pos]
+		if key := eventKey(event, c.start); key != "" {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				merged = append(merged, event)
+			}
+		} else {
+			merged = append(merged, event)
+		}
+
+		c.pos++
+		if c.pos < len(c.events) {
+			c.start = StartTime(c.events[c.pos])
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+
+	return merged
+}
+
+// eventKey identifies an occurrence of an event across calendars
+func eventKey(e *calendar.Event, start time.Time) string {
+	if e.ICalUID != "" {
+		return e.ICalUID + "@" + start.UTC().Format(time.RFC3339)
+	}
+
+	return e.Id
+}
+
+type cursor struct {
+	stream int
+	pos    int
+	events []*calendar.Event
+	start  time.Time
+}
+
+type cursorHeap []*cursor
+
+func (h cursorHeap) Len() int { return len(h) }
+
+func (h cursorHeap) Less(i, j int) bool {
+	if !h[i].start.Equal(h[j].start) {
+		return h[i].start.Before(h[j].start)
+	}
+
+	return h[i].stream < h[j].stream
+}
+
+func (h cursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *cursorHeap) Push(x interface{}) { *h = append(*h, x.(*cursor)) }
+
+func (h *cursorHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
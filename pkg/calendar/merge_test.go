@@ -0,0 +1,66 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func testEvent(id, uid string, hour int) *calendar.Event {
+	start := time.Date(2026, 10, 14, hour, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	return &calendar.Event{
+		Id:      id,
+		ICalUID: uid,
+		Start:   &calendar.EventDateTime{DateTime: start},
+		End:     &calendar.EventDateTime{DateTime: start},
+	}
+}
+
+func TestMergeEvents(t *testing.T) {
+	a1, a3, a5 := testEvent("a1", "", 1), testEvent("a3", "", 3), testEvent("a5", "", 5)
+	b2, b3, b4 := testEvent("b2", "", 2), testEvent("b3", "", 3), testEvent("b4", "", 4)
+	c0 := testEvent("c0", "", 0)
+	// one shared event as seen by two calendars, and an occurrence of it on another day
+	shared1, shared2 := testEvent("x", "shared", 2), testEvent("y", "shared", 2)
+	sharedLater := testEvent("z", "shared", 6)
+	// all-day events start at midnight of their day
+	allDay := &calendar.Event{Id: "allday", Start: &calendar.EventDateTime{Date: "2026-10-14"}}
+
+	tests := []struct {
+		name    string
+		streams [][]*calendar.Event
+		limit   int
+		want    []*calendar.Event
+	}{
+		{"empty", nil, 0, nil},
+		{"empty streams", [][]*calendar.Event{{}, nil}, 0, nil},
+		{"single", [][]*calendar.Event{{a1, a3}}, 0, []*calendar.Event{a1, a3}},
+		{"interleaved", [][]*calendar.Event{{a1, a3, a5}, {b2, b4}, {c0}}, 0, []*calendar.Event{c0, a1, b2, a3, b4, a5}},
+		// ties keep the order of the streams
+		{"ties", [][]*calendar.Event{{b3}, {a3}}, 0, []*calendar.Event{b3, a3}},
+		{"limit", [][]*calendar.Event{{a1, a3, a5}, {b2, b4}}, 3, []*calendar.Event{a1, b2, a3}},
+		{"no limit", [][]*calendar.Event{{a1}, {b2}}, -1, []*calendar.Event{a1, b2}},
+		{"shared", [][]*calendar.Event{{shared1, a3}, {b2, shared2, b4}}, 0, []*calendar.Event{shared1, b2, a3, b4}},
+		{"shared in limit", [][]*calendar.Event{{shared1, a3}, {shared2}}, 2, []*calendar.Event{shared1, a3}},
+		{"recurring shared", [][]*calendar.Event{{shared1, sharedLater}}, 0, []*calendar.Event{shared1, sharedLater}},
+		{"same id", [][]*calendar.Event{{a1}, {a1}}, 0, []*calendar.Event{a1}},
+		{"all day", [][]*calendar.Event{{a1}, {allDay}}, 0, []*calendar.Event{allDay, a1}},
+	}
+	for _, tt := range tests {
+		got := mergeEvents(tt.streams, tt.limit)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeEvents() = %v, want %v", tt.name, ids(got), ids(tt.want))
+		}
+	}
+}
+
+func ids(events []*calendar.Event) []string {
+	res := make([]string, 0, len(events))
+	for _, e := range events {
+		res = append(res, e.Id)
+	}
+
+	return res
+}
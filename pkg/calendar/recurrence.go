@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/timeparse"
+	"google.golang.org/api/calendar/v3"
+)
+
+var rruleFrequencies = map[timeparse.Frequency]string{
+	timeparse.Daily:   "DAILY",
+	timeparse.Weekly:  "WEEKLY",
+	timeparse.Monthly: "MONTHLY",
+}
+
+var rruleDays = map[time.Weekday]string{
+	time.Sunday:    "SU",
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+}
+
+// rrule converts a recurrence to the RFC 5545 rule google expects
+func rrule(r *timeparse.Recurrence) string {
+	parts := []string{"FREQ=" + rruleFrequencies[r.Frequency]}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.Weekdays) > 0 {
+		days := make([]string, 0, len(r.Weekdays))
+		for _, wd := range r.Weekdays {
+			days = append(days, rruleDays[wd])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	} else if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	return "RRULE:" + strings.Join(parts, ";")
+}
+
+// EventTime returns the api representation of t. The timezone is included when google
+// knows it, recurring events need one to expand their rule.
+func EventTime(t time.Time) *calendar.EventDateTime {
+	dt := &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)}
+	if name := t.Location().String(); name != "" && name != "Local" {
+		if _, err := time.LoadLocation(name); err == nil {
+			dt.TimeZone = name
+		}
+	}
+
+	return dt
+}
+
+// Occurrence returns the occurrence of a recurring event that starts within from and to
+func (cal *Calendar) Occurrence(ctx context.Context, calID, eventID string, from, to time.Time) (*calendar.Event, error) {
+	var instances *calendar.Events
+	err := cal.call(ctx, calID, func(ctx context.Context) (err error) {
+		instances, err = cal.Events.Instances(calID, eventID).
+			ShowDeleted(false).
+			TimeMin(from.Format(time.RFC3339)).
+			TimeMax(to.Format(time.RFC3339)).
+			Context(ctx).
+			Do()
+		return err
+	})
+	if isNotFound(err) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instances of %q: %w", eventID, err)
+	}
+	cal.track(calID, instances.TimeZone, instances.Items...)
+
+	for _, instance := range instances.Items {
+		if start := StartTime(instance); !start.Before(from) && start.Before(to) {
+			return instance, nil
+		}
+	}
+
+	return nil, ErrEventNotFound
+}
@@ -0,0 +1,182 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// callTimeout bounds all attempts of a single call to one calendar
+	callTimeout = time.Second * 5
+	maxAttempts = 3
+	baseBackoff = time.Millisecond * 200
+
+	// breakerThreshold is the amount of consecutive failures after which a calendar is skipped
+	breakerThreshold = 5
+	breakerCooldown  = time.Minute
+)
+
+// ErrCircuitOpen is returned for calendars that failed too often recently
+var ErrCircuitOpen = errors.New("calendar is temporarily disabled after repeated failures")
+
+// PartialError is returned alongside the results of the calendars that could be
+// queried if some, but not all, calendars failed.
+type PartialError struct {
+	Failed map[string]error
+}
+
+func (e *PartialError) Error() string {
+	ids := make([]string, 0, len(e.Failed))
+	for id := range e.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return fmt.Sprintf("failed to query %d calendars: %s", len(ids), strings.Join(ids, ", "))
+}
+
+// IsPartial returns true if err only reports some failed calendars
+func IsPartial(err error) bool {
+	var partial *PartialError
+	return errors.As(err, &partial)
+}
+
+// call runs an idempotent request against a calendar with a timeout, retries and circuit breaking
+func (cal *Calendar) call(ctx context.Context, calID string, req func(ctx context.Context) error) error {
+	return cal.guard(ctx, calID, func(ctx context.Context) error { return retry(ctx, req) })
+}
+
+// callOnce is like call, but never retries the request
+func (cal *Calendar) callOnce(ctx context.Context, calID string, req func(ctx context.Context) error) error {
+	return cal.guard(ctx, calID, req)
+}
+
+func (cal *Calendar) guard(ctx context.Context, calID string, req func(ctx context.Context) error) error {
+	b := cal.breaker(calID)
+	if !b.allow(time.Now()) {
+		return fmt.Errorf("calendar %q: %w", calID, ErrCircuitOpen)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	err := req(ctx)
+	if b.record(err, time.Now()) {
+		logrus.Warnf("calendar %q failed %d times in a row, pausing requests for %s", calID, breakerThreshold, breakerCooldown)
+	}
+
+	return err
+}
+
+func (cal *Calendar) breaker(calID string) *breaker {
+	cal.breakerLock.Lock()
+	defer cal.breakerLock.Unlock()
+
+	b, ok := cal.breakers[calID]
+	if !ok {
+		b = &breaker{}
+		cal.breakers[calID] = b
+	}
+
+	return b
+}
+
+// retry runs req until it succeeds, fails permanently, runs out of attempts or ctx expires
+func retry(ctx context.Context, req func(ctx context.Context) error) error {
+	backoff := baseBackoff
+	for attempt := 1; ; attempt++ {
+		err := req(ctx)
+		if err == nil || attempt == maxAttempts || !retryable(err) {
+			return err
+		}
+
+		// wait between half and one and a half times the backoff, so concurrent requests don't retry in lockstep
+		wait := time.Duration(rand.Int63n(int64(backoff))) + backoff/2
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// retryable returns true for rate limits and server side errors
+func retryable(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+
+	if gerr.Code == http.StatusTooManyRequests || gerr.Code >= http.StatusInternalServerError {
+		return true
+	}
+	if gerr.Code == http.StatusForbidden {
+		for _, item := range gerr.Errors {
+			if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// breaker stops requests to a calendar after repeated failures. Once the cooldown
+// passes a single request is let through; if it fails the breaker opens again.
+type breaker struct {
+	sync.Mutex
+
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+
+	return true
+}
+
+// record updates the breaker with the outcome of a request and returns true if it opened
+func (b *breaker) record(err error, now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	b.probing = false
+	if err == nil || googleapi.IsNotModified(err) || isNotFound(err) {
+		b.failures = 0
+		return false
+	}
+	// the caller giving up says nothing about the calendar
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openUntil = now.Add(breakerCooldown)
+		return b.failures == breakerThreshold
+	}
+
+	return false
+}
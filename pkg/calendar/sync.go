@@ -0,0 +1,243 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MemeLabs/whenis/pkg/search"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/calendar/v3"
+)
+
+// SyncWindow is how far into the future Sync keeps track of events
+const SyncWindow = time.Hour * 24 * 60
+
+type ChangeKind int
+
+const (
+	EventAdded ChangeKind = iota
+	EventMoved
+	EventCancelled
+)
+
+// Change describes how an event differs from the previous sync
+type Change struct {
+	Kind       ChangeKind
+	CalendarID string
+	Event      *calendar.Event
+	// Previous is the event as it was before it moved or got cancelled,
+	// for cancellations Event holds the last known state as well
+	Previous *calendar.Event
+}
+
+// Sync fetches all upcoming events of the searched calendars and returns how they
+// changed since the last sync. The first sync only records the current state.
+// Calendars that fail to sync keep their previous state, so their events are not
+// reported as cancelled.
+func (cal *Calendar) Sync(ctx context.Context) ([]Change, error) {
+	now := time.Now()
+	ids := cal.CalendarIDs(ctx)
+
+	type result struct {
+		id     string
+		events []*calendar.Event
+		err    error
+	}
+	resChan := make(chan result, len(ids))
+	for _, id := range ids {
+		id := id
+		go func() {
+			events, err := cal.listAll(ctx, id, now, now.Add(SyncWindow))
+			resChan <- result{id: id, events: events, err: err}
+		}()
+	}
+
+	partial := &PartialError{Failed: make(map[string]error)}
+	fresh := make(map[string]map[string]*calendar.Event, len(ids))
+	for range ids {
+		r := <-resChan
+		if r.err != nil {
+			logrus.Error(r.err)
+			partial.Failed[r.id] = r.err
+			continue
+		}
+		events := make(map[string]*calendar.Event, len(r.events))
+		for _, e := range r.events {
+			events[e.Id] = e
+		}
+		fresh[r.id] = events
+	}
+	if len(ids) > 0 && len(partial.Failed) == len(ids) {
+		return nil, fmt.Errorf("failed to sync calendars: %w", partial)
+	}
+
+	cal.syncLock.Lock()
+	var changes, missing []Change
+	for calID, events := range fresh {
+		old, known := cal.synced[calID]
+		if !known {
+			// calendars seen for the first time only set the baseline
+			continue
+		}
+		for id, e := range events {
+			prev, ok := old[id]
+			switch {
+			case !ok && e.Status != "cancelled" && createdAfter(e, cal.syncedAt[calID]):
+				changes = append(changes, Change{Kind: EventAdded, CalendarID: calID, Event: e})
+			case ok && e.Status == "cancelled":
+				changes = append(changes, Change{Kind: EventCancelled, CalendarID: calID, Event: prev, Previous: prev})
+			case ok && (!StartTime(e).Equal(StartTime(prev)) || !EndTime(e).Equal(EndTime(prev))):
+				changes = append(changes, Change{Kind: EventMoved, CalendarID: calID, Event: e, Previous: prev})
+			}
+		}
+		for id, prev := range old {
+			if _, ok := events[id]; ok {
+				continue
+			}
+			// events that ended just left the sync range, the others were deleted or moved out of it
+			if EndTime(prev).After(now) {
+				missing = append(missing, Change{Kind: EventCancelled, CalendarID: calID, Event: prev, Previous: prev})
+			}
+		}
+	}
+
+	for calID, events := range fresh {
+		for id, e := range events {
+			if e.Status == "cancelled" {
+				delete(events, id)
+			}
+		}
+		cal.synced[calID] = events
+		// failed calendars keep their time, events added meanwhile are reported once they sync
+		cal.syncedAt[calID] = now
+	}
+	for calID := range cal.synced {
+		if _, selected := fresh[calID]; !selected && partial.Failed[calID] == nil {
+			delete(cal.synced, calID)
+			delete(cal.syncedAt, calID)
+		}
+	}
+	cal.reindex()
+	cal.syncLock.Unlock()
+
+	for _, c := range missing {
+		e, err := cal.Event(ctx, c.CalendarID, c.Event.Id)
+		if err == nil && e.Status != "cancelled" {
+			c.Kind = EventMoved
+			c.Event = e
+		} else if err != nil && !errors.Is(err, ErrEventNotFound) {
+			logrus.Error(err)
+			continue
+		}
+		changes = append(changes, c)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return StartTime(changes[i].Event).Before(StartTime(changes[j].Event)) })
+
+	if len(partial.Failed) > 0 {
+		return changes, partial
+	}
+	return changes, nil
+}
+
+// createdAfter returns true if e was created after t. Older events that are new to the
+// sync, e.g. instances of a series that moved into the sync range, were not added.
+func createdAfter(e *calendar.Event, t time.Time) bool {
+	created, err := time.Parse(time.RFC3339, e.Created)
+	if err != nil {
+		return false
+	}
+
+	return created.After(t)
+}
+
+// Synced returns the events known from the last sync, sorted by starting time
+func (cal *Calendar) Synced() []*calendar.Event {
+	cal.syncLock.Lock()
+	defer cal.syncLock.Unlock()
+
+	return cal.sortedSynced()
+}
+
+// Search looks up events from the last sync with fuzzy matching, best matches first.
+// It returns nothing before the first sync.
+func (cal *Calendar) Search(query string, limit int) []*calendar.Event {
+	cal.syncLock.Lock()
+	defer cal.syncLock.Unlock()
+
+	if cal.index == nil {
+		return nil
+	}
+	results := cal.index.Search(query, time.Now(), limit)
+	events := make([]*calendar.Event, 0, len(results))
+	for _, r := range results {
+		events = append(events, cal.indexed[r.Doc])
+	}
+
+	return events
+}
+
+// reindex rebuilds the search index from the synced events, syncLock must be held
+func (cal *Calendar) reindex() {
+	cal.indexed = cal.sortedSynced()
+	docs := make([]search.Document, 0, len(cal.indexed))
+	for _, e := range cal.indexed {
+		docs = append(docs, search.Document{
+			Title:       e.Summary,
+			Description: e.Description,
+			Calendar:    cal.CalendarName(cal.CalendarOf(e)),
+			Start:       StartTime(e),
+			End:         EndTime(e),
+		})
+	}
+	cal.index = search.NewIndex(docs)
+}
+
+func (cal *Calendar) sortedSynced() []*calendar.Event {
+	var events []*calendar.Event
+	for _, calEvents := range cal.synced {
+		for _, e := range calEvents {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return StartTime(events[i]).Before(StartTime(events[j])) })
+
+	return events
+}
+
+// listAll fetches all events of a calendar that overlap the given range
+func (cal *Calendar) listAll(ctx context.Context, calID string, from, to time.Time) ([]*calendar.Event, error) {
+	var all []*calendar.Event
+	var pageToken string
+	for {
+		q := cal.Events.List(calID).
+			ShowDeleted(true).
+			SingleEvents(true).
+			OrderBy("startTime").
+			TimeMin(from.Format(time.RFC3339)).
+			TimeMax(to.Format(time.RFC3339)).
+			MaxResults(2500)
+		if pageToken != "" {
+			q.PageToken(pageToken)
+		}
+
+		var page *calendar.Events
+		err := cal.call(ctx, calID, func(ctx context.Context) (err error) {
+			page, err = q.Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to sync calendar %q: %w", calID, err)
+		}
+		cal.track(calID, page.TimeZone, page.Items...)
+		all = append(all, page.Items...)
+
+		if page.NextPageToken == "" {
+			return all, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,6 +15,8 @@ import (
 
 type Chat struct {
 	conn *websocket.Conn
+	// websocket connections support only one concurrent writer
+	writeLock sync.Mutex
 
 	MessageChan chan Message
 }
@@ -31,7 +34,7 @@ func (c *Chat) SendPriv(recipient Chatter, message string) error {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 	msgBytes = append([]byte("PRIVMSG "), msgBytes...)
-	err = c.conn.WriteMessage(websocket.TextMessage, msgBytes)
+	err = c.write(msgBytes)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -47,7 +50,7 @@ func (c *Chat) Send(message string) error {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 	msgBytes = append([]byte("MSG "), msgBytes...)
-	err = c.conn.WriteMessage(websocket.TextMessage, msgBytes)
+	err = c.write(msgBytes)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
@@ -55,6 +58,16 @@ func (c *Chat) Send(message string) error {
 	return nil
 }
 
+func (c *Chat) write(msg []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
 func Connect(ctx context.Context, wsUrl, jwt string) (*Chat, error) {
 	chat := &Chat{
 		MessageChan: make(chan Message, 10),
@@ -75,7 +88,9 @@ func (c *Chat) connectLoop(ctx context.Context, wsUrl, jwt string) {
 			backoff = min(backoff*2, time.Second*10)
 		} else {
 			backoff = time.Millisecond * 10
+			c.writeLock.Lock()
 			c.conn = conn
+			c.writeLock.Unlock()
 			if err = c.readLoop(ctx); err != nil {
 				logrus.Error("read loop failed:", err)
 			}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/MemeLabs/whenis/pkg/bot"
+	"github.com/MemeLabs/whenis/pkg/calendar"
+)
+
+// Config holds the settings of whenis that are too structured for flags
+type Config struct {
+	Calendar calendar.Config `json:"calendar"`
+	Bot      bot.Config      `json:"bot"`
+}
+
+// Load reads the config from a json file, an empty path yields the default config
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %w", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,295 @@
+// Package search implements a small fuzzy full-text index for event titles,
+// descriptions and calendar names.
+package search
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// field weights, title matches rank above description and calendar matches
+const (
+	titleWeight       = 3
+	descriptionWeight = 2
+	calendarWeight    = 1
+)
+
+// match qualities of a single query token
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.8
+	typoMatch   = 0.6
+)
+
+// minScore is the least relevance a document needs to be a result
+const minScore = 0.3
+
+// proximityDays is the time after which an event's rank is halved
+const proximityDays = 7
+
+// stopwords don't have to match, e.g. in "when is the next race"
+var stopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "is": true, "when": true,
+	"next": true, "of": true, "in": true, "on": true, "at": true,
+}
+
+// Document is a searchable event
+type Document struct {
+	Title       string
+	Description string
+	Calendar    string
+	Start       time.Time
+	End         time.Time
+}
+
+// Result is a document that matched a query, Doc is its index in the indexed slice
+type Result struct {
+	Doc   int
+	Score float64
+}
+
+type entry struct {
+	title, description, calendar []string
+	start, end                   time.Time
+}
+
+// Index holds the tokenized documents
+type Index struct {
+	entries []entry
+}
+
+// NewIndex tokenizes the given documents
+func NewIndex(docs []Document) *Index {
+	idx := &Index{entries: make([]entry, len(docs))}
+	for i, d := range docs {
+		title := Tokenize(d.Title)
+		if a := acronym(title); a != "" {
+			title = append(title, a)
+		}
+		idx.entries[i] = entry{
+			title:       title,
+			description: Tokenize(d.Description),
+			calendar:    Tokenize(d.Calendar),
+			start:       d.Start,
+			end:         d.End,
+		}
+	}
+
+	return idx
+}
+
+// Len returns the amount of indexed documents
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// Search returns up to limit documents that did not end before now, best matches first
+func (idx *Index) Search(query string, now time.Time, limit int) []Result {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var results []Result
+	for i, e := range idx.entries {
+		if !e.end.IsZero() && !e.end.After(now) {
+			continue
+		}
+		score, ok := e.relevance(tokens)
+		if !ok || score < minScore {
+			continue
+		}
+		results = append(results, Result{Doc: i, Score: score * proximity(e.start, now)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return idx.entries[results[i].Doc].start.Before(idx.entries[results[j].Doc].start)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// relevance scores how well the entry matches all query tokens between 0 and 1
+func (e entry) relevance(tokens []string) (float64, bool) {
+	var total float64
+	var counted int
+	for _, t := range tokens {
+		best := titleWeight * matchTokens(t, e.title)
+		if s := descriptionWeight * matchTokens(t, e.description); s > best {
+			best = s
+		}
+		if s := calendarWeight * matchTokens(t, e.calendar); s > best {
+			best = s
+		}
+		if best == 0 && stopwords[t] {
+			continue
+		}
+		if best == 0 {
+			return 0, false
+		}
+		total += best
+		counted++
+	}
+	if counted == 0 {
+		return 0, false
+	}
+
+	return total / float64(counted*titleWeight), true
+}
+
+// proximity ranks events that are ongoing or start soon higher
+func proximity(start, now time.Time) float64 {
+	days := start.Sub(now).Hours() / 24
+	if days < 0 {
+		days = 0
+	}
+
+	return 0.5 + 0.5/(1+days/proximityDays)
+}
+
+// matchTokens returns the quality of the best match of t within tokens
+func matchTokens(t string, tokens []string) float64 {
+	var best float64
+	for _, c := range tokens {
+		if s := matchToken(t, c); s > best {
+			best = s
+			if best == exactMatch {
+				break
+			}
+		}
+	}
+
+	return best
+}
+
+func matchToken(t, c string) float64 {
+	switch {
+	case t == c:
+		return exactMatch
+	case len(t) >= 2 && strings.HasPrefix(c, t):
+		return prefixMatch
+	}
+
+	allowed := maxTypos(len(t))
+	if allowed == 0 {
+		return 0
+	}
+	// a typo may also be in the part of the token that was typed so far
+	if len(c) > len(t) && strings.HasPrefix(c, t[:len(t)-1]) {
+		return typoMatch
+	}
+	if d := distance(t, c, allowed); d <= allowed {
+		return typoMatch - 0.1*float64(d-1)
+	}
+
+	return 0
+}
+
+// maxTypos is the amount of edits allowed for a token of length n
+func maxTypos(n int) int {
+	switch {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance returns the optimal string alignment distance of a and b, or max+1 if it exceeds max
+func distance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, o := range vs {
+		if o < v {
+			v = o
+		}
+	}
+
+	return v
+}
+
+// acronym returns the initials of the words followed by whole numbers, e.g. "f1" for "formula 1"
+func acronym(tokens []string) string {
+	if len(tokens) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, t := range tokens {
+		r := []rune(t)
+		if unicode.IsDigit(r[0]) {
+			b.WriteString(t)
+		} else {
+			b.WriteRune(r[0])
+		}
+	}
+
+	return b.String()
+}
+
+// Fold lowercases s and removes diacritics, e.g. "Café" becomes "cafe"
+func Fold(s string) string {
+	// transformers keep state between calls, so every call gets its own chain
+	folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(folder, s)
+	if err != nil {
+		folded = s
+	}
+
+	return strings.ToLower(folded)
+}
+
+// Tokenize splits folded text into words and numbers
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
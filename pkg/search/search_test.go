@@ -0,0 +1,144 @@
+package search
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"Café", "cafe"},
+		{"Grand Prix São Paulo", "grand prix sao paulo"},
+		{"Ångström", "angstrom"},
+		{"ALREADY folded", "already folded"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Fold(tt.input); got != tt.want {
+			t.Errorf("Fold(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestFoldConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got := Fold("Grand Prix São Paulo"); got != "grand prix sao paulo" {
+					t.Errorf("Fold() = %q", got)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"Formula 1: Grand Prix", []string{"formula", "1", "grand", "prix"}},
+		{"Re:Zero - Episode #12", []string{"re", "zero", "episode", "12"}},
+		{"Mañana!!", []string{"manana"}},
+		{"  ", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"race", "race", 1, 0},
+		{"race", "rase", 1, 1},
+		{"race", "rcae", 1, 1},
+		{"formula", "fromula", 1, 1},
+		{"qualifying", "qualifyng", 2, 1},
+		{"race", "movie", 1, 2},
+		{"f1", "formula", 2, 3},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("distance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	day := time.Hour * 24
+	docs := []Document{
+		{Title: "Formula 1 Grand Prix", Calendar: "Motorsport", Start: now.Add(day * 3), End: now.Add(day*3 + time.Hour*2)},
+		{Title: "Movie Night", Description: "we watch a race movie", Calendar: "Community", Start: now.Add(day), End: now.Add(day + time.Hour*3)},
+		{Title: "Formula 1 Qualifying", Calendar: "Motorsport", Start: now.Add(day * 2), End: now.Add(day*2 + time.Hour)},
+		{Title: "Formula 1 Practice", Calendar: "Motorsport", Start: now.Add(-day), End: now.Add(-day + time.Hour)},
+		{Title: "Café Stream", Calendar: "Community", Start: now.Add(day * 30), End: now.Add(day*30 + time.Hour)},
+		{Title: "Speedrun Marathon", Calendar: "Games", Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+	}
+	idx := NewIndex(docs)
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []int
+	}{
+		// sooner events rank higher among equal matches, ended ones are left out
+		{"prefix", "formula", 0, []int{2, 0}},
+		{"acronym", "f1", 0, []int{2, 0}},
+		{"all tokens", "formula qualifying", 0, []int{2}},
+		{"typo", "qualifyng", 0, []int{2}},
+		{"transposed", "fromula", 0, []int{2, 0}},
+		{"stopwords", "when is the next grand prix", 0, []int{0}},
+		{"folded", "cafe", 0, []int{4}},
+		{"calendar", "motorsport", 0, []int{2, 0}},
+		{"title", "movie", 0, []int{1}},
+		{"description", "race", 0, []int{1}},
+		{"ongoing", "speedrun", 0, []int{5}},
+		{"limit", "formula", 1, []int{2}},
+		{"no match", "basketball", 0, nil},
+		{"only stopwords", "when is the", 0, nil},
+		{"empty", "", 0, nil},
+	}
+	for _, tt := range tests {
+		var got []int
+		for _, r := range idx.Search(tt.query, now, tt.limit) {
+			got = append(got, r.Doc)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Search(%q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchRanksTitleAboveDescription(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	start := now.Add(time.Hour)
+	idx := NewIndex([]Document{
+		{Title: "Watch Party", Description: "the race on the big screen", Start: start},
+		{Title: "Race Day", Start: start},
+	})
+
+	results := idx.Search("race", now, 0)
+	if len(results) != 2 || results[0].Doc != 1 {
+		t.Fatalf("Search() = %v, want the title match first", results)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("title score %v is not above description score %v", results[0].Score, results[1].Score)
+	}
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store persists small json records grouped into buckets. Every write is flushed
+// to disk right away, so it is meant for data that changes at chat speed.
+type Store struct {
+	sync.Mutex
+
+	path    string
+	buckets map[string]map[string]json.RawMessage
+}
+
+// Open loads the store at path, creating it on the first write if it does not
+// exist. An empty path yields a store that only lives in memory.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		buckets: make(map[string]map[string]json.RawMessage),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.buckets); err != nil {
+		return nil, fmt.Errorf("failed to parse store: %w", err)
+	}
+
+	return s, nil
+}
+
+// Get decodes the record stored under key into v and reports whether it exists
+func (s *Store) Get(bucket, key string, v interface{}) (bool, error) {
+	s.Lock()
+	raw, ok := s.buckets[bucket][key]
+	s.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return true, fmt.Errorf("failed to decode %s/%s: %w", bucket, key, err)
+	}
+
+	return true, nil
+}
+
+// Put stores v under key, replacing any previous record
+func (s *Store) Put(bucket, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", bucket, key, err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	b, ok := s.buckets[bucket]
+	if !ok {
+		b = make(map[string]json.RawMessage)
+		s.buckets[bucket] = b
+	}
+	b[key] = raw
+
+	return s.flush()
+}
+
+// Delete removes the record stored under key
+func (s *Store) Delete(bucket, key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.buckets[bucket][key]; !ok {
+		return nil
+	}
+	delete(s.buckets[bucket], key)
+
+	return s.flush()
+}
+
+// ForEach calls fn for every record in a bucket. It works on a snapshot, so fn
+// may modify the store.
+func (s *Store) ForEach(bucket string, fn func(key string, raw json.RawMessage) error) error {
+	s.Lock()
+	snapshot := make(map[string]json.RawMessage, len(s.buckets[bucket]))
+	for k, v := range s.buckets[bucket] {
+		snapshot[k] = v
+	}
+	s.Unlock()
+
+	for k, v := range snapshot {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// flush writes the store to disk, the caller must hold the lock
+func (s *Store) flush() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.buckets)
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated store
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+
+	return nil
+}
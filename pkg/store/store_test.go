@@ -0,0 +1,203 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+type record struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	for _, p := range []string{"", path} {
+		s, err := Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		steps := []struct {
+			name   string
+			op     func() error
+			bucket string
+			key    string
+			want   *record
+		}{
+			{"missing", nil, "a", "x", nil},
+			{"put", func() error { return s.Put("a", "x", record{"one", 1}) }, "a", "x", &record{"one", 1}},
+			{"other bucket", func() error { return s.Put("b", "x", record{"two", 2}) }, "a", "x", &record{"one", 1}},
+			{"replace", func() error { return s.Put("a", "x", record{"three", 3}) }, "a", "x", &record{"three", 3}},
+			{"delete", func() error { return s.Delete("a", "x") }, "a", "x", nil},
+			{"delete missing", func() error { return s.Delete("c", "x") }, "b", "x", &record{"two", 2}},
+		}
+		for _, step := range steps {
+			if step.op != nil {
+				if err := step.op(); err != nil {
+					t.Fatalf("%q %s: %v", p, step.name, err)
+				}
+			}
+			var got record
+			ok, err := s.Get(step.bucket, step.key, &got)
+			if err != nil {
+				t.Fatalf("%q %s: %v", p, step.name, err)
+			}
+			if ok != (step.want != nil) || ok && got != *step.want {
+				t.Errorf("%q %s: Get() = %v %+v, want %+v", p, step.name, ok, got, step.want)
+			}
+		}
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("prefs", "chatter", record{"tz", 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("prefs", "gone", record{"tz", 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("prefs", "gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got record
+	if ok, err := reopened.Get("prefs", "chatter", &got); !ok || err != nil || got != (record{"tz", 2}) {
+		t.Errorf("Get() = %v %v %+v after reopening", ok, err, got)
+	}
+	if ok, _ := reopened.Get("prefs", "gone", &got); ok {
+		t.Error("deleted record is back after reopening")
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := ioutil.WriteFile(corrupt, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		ok   bool
+	}{
+		{"memory", "", true},
+		{"missing", filepath.Join(dir, "missing.json"), true},
+		{"corrupt", corrupt, false},
+		{"directory", dir, false},
+	}
+	for _, tt := range tests {
+		if _, err := Open(tt.path); (err == nil) != tt.ok {
+			t.Errorf("%s: Open() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestGetDecodeError(t *testing.T) {
+	s, _ := Open("")
+	if err := s.Put("a", "x", "text"); err != nil {
+		t.Fatal(err)
+	}
+	var got record
+	if ok, err := s.Get("a", "x", &got); !ok || err == nil {
+		t.Errorf("Get() = %v %v, want an existing record that fails to decode", ok, err)
+	}
+}
+
+func TestForEach(t *testing.T) {
+	s, _ := Open("")
+	for i := 0; i < 5; i++ {
+		if err := s.Put("a", fmt.Sprint(i), record{Count: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// fn may change the store while iterating
+	var keys []string
+	err := s.ForEach("a", func(key string, raw json.RawMessage) error {
+		var r record
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		if r.Count%2 == 0 {
+			return s.Delete("a", key)
+		}
+		return s.Put("b", key, r)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if fmt.Sprint(keys) != "[0 1 2 3 4]" {
+		t.Errorf("ForEach() visited %v", keys)
+	}
+
+	count := func(bucket string) int {
+		n := 0
+		_ = s.ForEach(bucket, func(string, json.RawMessage) error { n++; return nil })
+		return n
+	}
+	if a, b := count("a"), count("b"); a != 2 || b != 2 {
+		t.Errorf("buckets hold %d and %d records, want 2 and 2", a, b)
+	}
+
+	stop := fmt.Errorf("stop")
+	if err := s.ForEach("a", func(string, json.RawMessage) error { return stop }); err != stop {
+		t.Errorf("ForEach() error = %v, want %v", err, stop)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "store.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprint(i)
+			for j := 0; j < 20; j++ {
+				if err := s.Put("a", key, record{Count: j}); err != nil {
+					t.Error(err)
+					return
+				}
+				var r record
+				if _, err := s.Get("a", key, &r); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	reopened, err := Open(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		var r record
+		if ok, _ := reopened.Get("a", fmt.Sprint(i), &r); !ok || r.Count != 19 {
+			t.Errorf("record %d = %v %+v after reopening", i, ok, r)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package timeparse
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the unit a recurrence repeats in
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+)
+
+var (
+	ErrMissingFrequency = errors.New("missing how often it repeats, e.g. `every tuesday`")
+	ErrCountAndUntil    = errors.New("give either a number of times or an end, not both")
+	ErrTooManyTimes     = fmt.Errorf("an event can repeat at most %d times", maxCount)
+)
+
+// maxCount is the most occurrences a rule may have, the same as google calendar allows
+const maxCount = 730
+
+// Recurrence describes how an event repeats, independently of any calendar backend
+type Recurrence struct {
+	Frequency Frequency `json:"frequency"`
+	// Interval repeats every n-th day, week or month, 1 if unset
+	Interval int `json:"interval,omitempty"`
+	// Weekdays limits weekly rules to these days, the first occurrence's day if empty
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`
+	// Until is the latest start of an occurrence, zero if the rule has no end
+	Until time.Time `json:"until,omitempty"`
+	// Count limits the number of occurrences, 0 if unlimited
+	Count int `json:"count,omitempty"`
+	// HasClock is set if the rule named a time of day, e.g. "every tuesday at 20:00"
+	HasClock bool `json:"hasClock,omitempty"`
+	Hour     int  `json:"hour,omitempty"`
+	Minute   int  `json:"minute,omitempty"`
+}
+
+var noRecurrence = map[string]bool{"no": true, "none": true, "once": true, "never": true, "-": true}
+
+var (
+	workWeek = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	weekend  = []time.Weekday{time.Saturday, time.Sunday}
+)
+
+// ParseRecurrence reads rules like "every tuesday at 20:00 until december", "weekdays",
+// "every 2 weeks" or "daily 10 times". Dates are resolved against now in loc. It returns
+// nil for answers like "no" or "once".
+func ParseRecurrence(input string, now time.Time, loc *time.Location) (*Recurrence, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	if input == "" {
+		return nil, ErrEmpty
+	}
+	if noRecurrence[input] {
+		return nil, nil
+	}
+
+	r := &Recurrence{Interval: 1}
+	fields := strings.Fields(strings.NewReplacer(",", " ", " and ", " ", "&", " ").Replace(input))
+	for i := 0; i < len(fields); i++ {
+		word := fields[i]
+		next := ""
+		if i+1 < len(fields) {
+			next = fields[i+1]
+		}
+
+		switch {
+		case word == "every" || word == "each" || word == "on" || word == "repeats" || word == "repeating":
+		case word == "other":
+			r.Interval = 2
+		case isDigits(word) && (next == "times" || next == "x"):
+			count, err := strconv.Atoi(word)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid number of times %q", word)
+			}
+			if count > maxCount {
+				return nil, ErrTooManyTimes
+			}
+			r.Count = count
+			i++
+		case isDigits(word):
+			interval, err := strconv.Atoi(word)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid interval %q", word)
+			}
+			r.Interval = interval
+		case word == "day" || word == "days" || word == "daily":
+			r.Frequency = Daily
+		case word == "week" || word == "weeks" || word == "weekly":
+			r.Frequency = Weekly
+		case word == "biweekly" || word == "fortnightly":
+			r.Frequency = Weekly
+			r.Interval = 2
+		case word == "month" || word == "months" || word == "monthly":
+			r.Frequency = Monthly
+		case word == "weekday" || word == "weekdays":
+			r.Frequency = Weekly
+			r.Weekdays = append(r.Weekdays, workWeek...)
+		case word == "weekend" || word == "weekends":
+			r.Frequency = Weekly
+			r.Weekdays = append(r.Weekdays, weekend...)
+		case isWeekday(word) || isWeekday(strings.TrimSuffix(word, "s")):
+			wd, ok := weekdays[word]
+			if !ok {
+				wd = weekdays[strings.TrimSuffix(word, "s")]
+			}
+			r.Frequency = Weekly
+			r.Weekdays = append(r.Weekdays, wd)
+		case word == "at":
+			h, m, err := parseClock(next)
+			if err != nil {
+				return nil, err
+			}
+			r.HasClock, r.Hour, r.Minute = true, h, m
+			i++
+		case clock.MatchString(word) && strings.ContainsAny(word, ":apm"):
+			h, m, err := parseClock(word)
+			if err != nil {
+				return nil, err
+			}
+			r.HasClock, r.Hour, r.Minute = true, h, m
+		case word == "until" || word == "till" || word == "through":
+			until, err := parseUntil(strings.Join(fields[i+1:], " "), now, loc)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = until
+			i = len(fields)
+		default:
+			return nil, fmt.Errorf("could not understand %q", word)
+		}
+	}
+	if r.Frequency == 0 {
+		return nil, ErrMissingFrequency
+	}
+	// RFC 5545 does not allow rules with both
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, ErrCountAndUntil
+	}
+
+	return r, nil
+}
+
+// parseUntil returns the end of the day or month described by input
+func parseUntil(input string, now time.Time, loc *time.Location) (time.Time, error) {
+	if m, ok := months[input]; ok {
+		y := now.In(loc).Year()
+		if m < now.In(loc).Month() {
+			y++
+		}
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, loc).Add(-time.Second), nil
+	}
+
+	day, err := ParseDate(input, now, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid end %q: %w", input, err)
+	}
+
+	return day.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// Matches returns an error if start can't be the first occurrence of the rule
+func (r *Recurrence) Matches(start time.Time) error {
+	if r.HasClock && (start.Hour() != r.Hour || start.Minute() != r.Minute) {
+		return fmt.Errorf("the event starts at %s, but repeats at %02d:%02d", start.Format("15:04"), r.Hour, r.Minute)
+	}
+	if !r.Until.IsZero() && r.Until.Before(start) {
+		return errors.New("the repetition ends before the event starts")
+	}
+	if len(r.Weekdays) == 0 {
+		return nil
+	}
+	for _, wd := range r.Weekdays {
+		if wd == start.Weekday() {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the event starts on a %s, but repeats on %s", start.Weekday(), fmtWeekdays(r.Weekdays))
+}
+
+// LastStart returns the start of the last occurrence of a rule that begins at start,
+// or the zero time if the rule repeats forever
+func (r *Recurrence) LastStart(start time.Time) time.Time {
+	if r.Count <= 0 {
+		return r.Until
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch {
+	case r.Frequency == Daily:
+		return start.AddDate(0, 0, (r.Count-1)*interval)
+	case r.Frequency == Monthly:
+		// months without the day of the start are skipped, the 29th of february can be 8 years apart
+		last := start
+		for step, n := 0, 0; n < r.Count && step < r.Count*12*8; step++ {
+			t := time.Date(start.Year(), start.Month()+time.Month(step*interval), start.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+			if t.Day() == start.Day() {
+				last = t
+				n++
+			}
+		}
+		return last
+	case len(r.Weekdays) == 0:
+		return start.AddDate(0, 0, 7*(r.Count-1)*interval)
+	}
+
+	// weeks start on monday, only every interval-th week counts
+	offset := func(wd time.Weekday) int { return (int(wd) + 6) % 7 }
+	days := make([]int, 0, len(r.Weekdays))
+	seen := make(map[int]bool)
+	for _, wd := range r.Weekdays {
+		if !seen[offset(wd)] {
+			seen[offset(wd)] = true
+			days = append(days, offset(wd))
+		}
+	}
+	sort.Ints(days)
+
+	monday := start.AddDate(0, 0, -offset(start.Weekday()))
+	first := days[sort.SearchInts(days, offset(start.Weekday())):]
+	if r.Count <= len(first) {
+		return monday.AddDate(0, 0, first[r.Count-1])
+	}
+	rest := r.Count - len(first) - 1
+
+	return monday.AddDate(0, 0, 7*interval*(rest/len(days)+1)+days[rest%len(days)])
+}
+
+func (r *Recurrence) String() string {
+	var s string
+	unit := map[Frequency]string{Daily: "day", Weekly: "week", Monthly: "month"}[r.Frequency]
+	switch {
+	case r.Interval == 2 && len(r.Weekdays) > 0:
+		s = "every other " + fmtWeekdays(r.Weekdays)
+	case len(r.Weekdays) > 0:
+		s = "every " + fmtWeekdays(r.Weekdays)
+	case r.Interval == 2:
+		s = "every other " + unit
+	case r.Interval > 2:
+		s = fmt.Sprintf("every %d %ss", r.Interval, unit)
+	default:
+		s = "every " + unit
+	}
+	if r.Count > 0 {
+		s += fmt.Sprintf(", %d times", r.Count)
+	}
+	if !r.Until.IsZero() {
+		s += " until " + r.Until.Format("Jan 2 2006")
+	}
+
+	return s
+}
+
+func fmtWeekdays(days []time.Weekday) string {
+	names := make([]string, 0, len(days))
+	for _, wd := range days {
+		names = append(names, wd.String()[:3])
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,150 @@
+package timeparse
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	endOf := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Add(-time.Second)
+	}
+
+	tests := []struct {
+		input string
+		want  *Recurrence
+		err   error
+	}{
+		{"no", nil, nil},
+		{"once", nil, nil},
+		{"daily", &Recurrence{Frequency: Daily, Interval: 1}, nil},
+		{"every tuesday", &Recurrence{Frequency: Weekly, Interval: 1, Weekdays: []time.Weekday{time.Tuesday}}, nil},
+		{"tuesdays and thursdays", &Recurrence{Frequency: Weekly, Interval: 1, Weekdays: []time.Weekday{time.Tuesday, time.Thursday}}, nil},
+		{"weekdays", &Recurrence{Frequency: Weekly, Interval: 1, Weekdays: workWeek}, nil},
+		{"weekends", &Recurrence{Frequency: Weekly, Interval: 1, Weekdays: weekend}, nil},
+		{"every other week", &Recurrence{Frequency: Weekly, Interval: 2}, nil},
+		{"biweekly", &Recurrence{Frequency: Weekly, Interval: 2}, nil},
+		{"every 3 months", &Recurrence{Frequency: Monthly, Interval: 3}, nil},
+		{"daily 5 times", &Recurrence{Frequency: Daily, Interval: 1, Count: 5}, nil},
+		{"every monday 730 times", &Recurrence{Frequency: Weekly, Interval: 1, Weekdays: []time.Weekday{time.Monday}, Count: 730}, nil},
+		{"every tuesday at 8pm", &Recurrence{Frequency: Weekly, Interval: 1, Weekdays: []time.Weekday{time.Tuesday}, HasClock: true, Hour: 20}, nil},
+		{"every friday 20:15", &Recurrence{Frequency: Weekly, Interval: 1, Weekdays: []time.Weekday{time.Friday}, HasClock: true, Hour: 20, Minute: 15}, nil},
+		{"every 2 weeks until december", &Recurrence{Frequency: Weekly, Interval: 2, Until: endOf(2026, 12, 31)}, nil},
+		// months that passed this year mean next year
+		{"weekly until march", &Recurrence{Frequency: Weekly, Interval: 1, Until: endOf(2027, 3, 31)}, nil},
+		{"daily until 2026-11-01", &Recurrence{Frequency: Daily, Interval: 1, Until: endOf(2026, 11, 1)}, nil},
+		{"", nil, ErrEmpty},
+		{"at 20:00", nil, ErrMissingFrequency},
+		{"daily 5 times until december", nil, ErrCountAndUntil},
+		{"daily 731 times", nil, ErrTooManyTimes},
+	}
+	for _, tt := range tests {
+		got, err := ParseRecurrence(tt.input, now, time.UTC)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseRecurrence(%q) error = %v, want %v", tt.input, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRecurrence(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseRecurrenceInvalid(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	for _, input := range []string{"sometimes", "every 0 days", "daily 0 times", "daily 99999999999999999999 times", "every 99999999999999999999 days", "daily at 25:00", "weekly until whenever"} {
+		if got, err := ParseRecurrence(input, now, time.UTC); err == nil {
+			t.Errorf("ParseRecurrence(%q) = %+v, want an error", input, got)
+		}
+	}
+}
+
+func TestRecurrenceMatches(t *testing.T) {
+	// a tuesday evening
+	start := time.Date(2026, 11, 3, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		r    Recurrence
+		ok   bool
+	}{
+		{"daily", Recurrence{Frequency: Daily}, true},
+		{"same weekday", Recurrence{Frequency: Weekly, Weekdays: []time.Weekday{time.Tuesday}}, true},
+		{"workweek", Recurrence{Frequency: Weekly, Weekdays: workWeek}, true},
+		{"other weekday", Recurrence{Frequency: Weekly, Weekdays: []time.Weekday{time.Wednesday}}, false},
+		{"weekend", Recurrence{Frequency: Weekly, Weekdays: weekend}, false},
+		{"same clock", Recurrence{Frequency: Daily, HasClock: true, Hour: 20}, true},
+		{"other clock", Recurrence{Frequency: Daily, HasClock: true, Hour: 21}, false},
+		{"ends later", Recurrence{Frequency: Daily, Until: start.AddDate(0, 1, 0)}, true},
+		{"ends before", Recurrence{Frequency: Daily, Until: start.Add(-time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.r.Matches(start); (err == nil) != tt.ok {
+			t.Errorf("%s: Matches() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestRecurrenceLastStart(t *testing.T) {
+	// a tuesday evening
+	start := time.Date(2026, 11, 3, 20, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		r    Recurrence
+		from time.Time
+		want time.Time
+	}{
+		{"forever", Recurrence{Frequency: Daily}, start, time.Time{}},
+		{"until", Recurrence{Frequency: Weekly, Until: until}, start, until},
+		{"once", Recurrence{Frequency: Daily, Count: 1}, start, start},
+		{"daily", Recurrence{Frequency: Daily, Count: 5}, start, start.AddDate(0, 0, 4)},
+		{"every other day", Recurrence{Frequency: Daily, Interval: 2, Count: 3}, start, start.AddDate(0, 0, 4)},
+		{"weekly", Recurrence{Frequency: Weekly, Count: 3}, start, start.AddDate(0, 0, 14)},
+		{"biweekly", Recurrence{Frequency: Weekly, Interval: 2, Count: 3}, start, start.AddDate(0, 0, 28)},
+		// tue, thu, tue, thu
+		{"two weekdays", Recurrence{Frequency: Weekly, Weekdays: []time.Weekday{time.Tuesday, time.Thursday}, Count: 4}, start, start.AddDate(0, 0, 9)},
+		// tue 3, thu 5, then the week of the 16th
+		{"two weekdays every other week", Recurrence{Frequency: Weekly, Interval: 2, Weekdays: []time.Weekday{time.Tuesday, time.Thursday}, Count: 3}, start, start.AddDate(0, 0, 14)},
+		// a week starts on monday, so sunday the 8th still belongs to the first week
+		{"weekend every other week", Recurrence{Frequency: Weekly, Interval: 2, Weekdays: weekend, Count: 3}, time.Date(2026, 11, 7, 12, 0, 0, 0, time.UTC), time.Date(2026, 11, 21, 12, 0, 0, 0, time.UTC)},
+		{"weekdays in any order", Recurrence{Frequency: Weekly, Weekdays: []time.Weekday{time.Thursday, time.Tuesday, time.Thursday}, Count: 4}, start, start.AddDate(0, 0, 9)},
+		// starting on a wednesday, the first thursday is in the same week
+		{"weekdays from midweek", Recurrence{Frequency: Weekly, Weekdays: []time.Weekday{time.Tuesday, time.Wednesday, time.Thursday}, Count: 5}, start.AddDate(0, 0, 1), start.AddDate(0, 0, 9)},
+		{"many weeks apart", Recurrence{Frequency: Weekly, Interval: 52, Weekdays: []time.Weekday{time.Tuesday}, Count: 730}, start, start.AddDate(0, 0, 7*52*729)},
+		{"monthly", Recurrence{Frequency: Monthly, Count: 3}, start, time.Date(2027, 1, 3, 20, 0, 0, 0, time.UTC)},
+		// february, april and june have no 31st
+		{"monthly on the 31st", Recurrence{Frequency: Monthly, Count: 4}, time.Date(2027, 1, 31, 20, 0, 0, 0, time.UTC), time.Date(2027, 7, 31, 20, 0, 0, 0, time.UTC)},
+		// 2100 is no leap year
+		{"monthly on february 29th", Recurrence{Frequency: Monthly, Interval: 12, Count: 20}, time.Date(2028, 2, 29, 20, 0, 0, 0, time.UTC), time.Date(2108, 2, 29, 20, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.r.LastStart(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: LastStart() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecurrenceString(t *testing.T) {
+	tests := []struct {
+		r    Recurrence
+		want string
+	}{
+		{Recurrence{Frequency: Daily, Interval: 1}, "every day"},
+		{Recurrence{Frequency: Weekly, Interval: 2}, "every other week"},
+		{Recurrence{Frequency: Monthly, Interval: 3}, "every 3 months"},
+		{Recurrence{Frequency: Weekly, Interval: 1, Weekdays: workWeek}, "every Mon, Tue, Wed, Thu, Fri"},
+		{Recurrence{Frequency: Weekly, Interval: 2, Weekdays: []time.Weekday{time.Friday}}, "every other Fri"},
+		{Recurrence{Frequency: Daily, Interval: 1, Count: 5}, "every day, 5 times"},
+		{Recurrence{Frequency: Weekly, Interval: 1, Until: time.Date(2026, 12, 31, 23, 59, 59, 0, time.UTC)}, "every week until Dec 31 2026"},
+	}
+	for _, tt := range tests {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
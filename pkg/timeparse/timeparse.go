@@ -0,0 +1,379 @@
+// Package timeparse reads points in time the way people write them in chat,
+// e.g. "tomorrow 8pm EST", "friday 20:00 CET", "next sat noon" or "in 2h".
+package timeparse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrEmpty       = errors.New("no time given")
+	ErrMissingTime = errors.New("missing a time of day")
+	// ErrMissingDate and ErrUnexpectedTime are returned by ParseDate
+	ErrMissingDate    = errors.New("missing a day")
+	ErrUnexpectedTime = errors.New("expected a day without a time of day")
+)
+
+// Parse returns the point in time described by input. Relative expressions are
+// resolved against now, and times without a timezone are read in loc.
+func Parse(input string, now time.Time, loc *time.Location) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return time.Time{}, ErrEmpty
+	}
+
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		return t, nil
+	}
+	if unix, err := strconv.ParseInt(input, 10, 64); err == nil && len(input) >= 9 {
+		return time.Unix(unix, 0), nil
+	}
+
+	lower := strings.ToLower(input)
+	if lower == "now" {
+		return now, nil
+	}
+	if strings.HasPrefix(lower, "in ") {
+		d, err := ParseDuration(strings.TrimPrefix(lower, "in "))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+
+	e, err := parseExpr(input)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !e.hasClock {
+		return time.Time{}, ErrMissingTime
+	}
+
+	return e.resolve(now, loc), nil
+}
+
+// ParseDate returns the midnight of the day described by input, e.g. "friday",
+// "tomorrow" or "nov 3". Unlike Parse it treats today as upcoming.
+func ParseDate(input string, now time.Time, loc *time.Location) (time.Time, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return time.Time{}, ErrEmpty
+	}
+	if t, err := time.ParseInLocation("2006-01-02", input, loc); err == nil {
+		return t, nil
+	}
+
+	e, err := parseExpr(input)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if e.hasClock {
+		return time.Time{}, ErrUnexpectedTime
+	}
+	if !e.hasDate && !e.hasWeekday && !e.hasOffset {
+		return time.Time{}, ErrMissingDate
+	}
+	if e.loc != nil {
+		loc = e.loc
+	}
+	y, m, d := now.In(loc).Date()
+
+	return e.resolve(time.Date(y, m, d, 0, 0, 0, 0, loc), loc), nil
+}
+
+var durationUnit = regexp.MustCompile(`^(\d+)\s*(d|days?|h|hrs?|hours?|m|mins?|minutes?)$`)
+
+// ParseDuration accepts go durations ("2h5m") as well as spelled out ones ("2 hours 5 minutes")
+func ParseDuration(input string) (time.Duration, error) {
+	input = strings.TrimSpace(strings.ToLower(input))
+	if d, err := time.ParseDuration(input); err == nil {
+		return d, nil
+	}
+
+	var total time.Duration
+	fields := strings.Fields(strings.NewReplacer(",", " ", " and ", " ").Replace(input))
+	for i := 0; i < len(fields); i++ {
+		part := fields[i]
+		if i+1 < len(fields) && isDigits(part) {
+			part += fields[i+1]
+			i++
+		}
+		m := durationUnit.FindStringSubmatch(part)
+		if m == nil {
+			return 0, fmt.Errorf("invalid duration %q", input)
+		}
+		n, _ := strconv.Atoi(m[1])
+		switch m[2][0] {
+		case 'd':
+			total += time.Duration(n) * time.Hour * 24
+		case 'h':
+			total += time.Duration(n) * time.Hour
+		case 'm':
+			total += time.Duration(n) * time.Minute
+		}
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("invalid duration %q", input)
+	}
+
+	return total, nil
+}
+
+// expr holds the parts of a parsed time expression
+type expr struct {
+	loc *time.Location
+
+	// either a fixed date, a weekday or a day offset from today
+	hasDate    bool
+	year       int
+	month      time.Month
+	day        int
+	hasWeekday bool
+	weekday    time.Weekday
+	skipToday  bool
+	hasOffset  bool
+	dayOffset  int
+
+	hasClock bool
+	hour     int
+	minute   int
+}
+
+var (
+	isoDate   = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
+	clock     = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	dayNumber = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)?$`)
+	offset    = regexp.MustCompile(`^(?:utc|gmt)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+)
+
+func parseExpr(input string) (*expr, error) {
+	e := &expr{}
+	fields := strings.Fields(strings.NewReplacer(",", " ", ".", " ").Replace(input))
+
+	for i := 0; i < len(fields); i++ {
+		word := strings.ToLower(fields[i])
+		next := ""
+		if i+1 < len(fields) {
+			next = strings.ToLower(fields[i+1])
+		}
+
+		switch {
+		case word == "at" || word == "on" || word == "of" || word == "the":
+		case word == "today":
+			e.setOffset(0)
+		case word == "tonight":
+			e.setOffset(0)
+			if !e.hasClock {
+				e.setClock(20, 0)
+			}
+		case word == "tomorrow" || word == "tmrw" || word == "tmr":
+			e.setOffset(1)
+		case word == "noon" || word == "midday":
+			e.setClock(12, 0)
+		case word == "midnight":
+			e.setClock(0, 0)
+		case word == "next" || word == "this":
+			wd, ok := weekdays[next]
+			if !ok {
+				return nil, fmt.Errorf("expected a weekday after %q", word)
+			}
+			e.setWeekday(wd, word == "next")
+			i++
+		case isWeekday(word):
+			e.setWeekday(weekdays[word], false)
+		case isoDate.MatchString(word):
+			m := isoDate.FindStringSubmatch(word)
+			y, _ := strconv.Atoi(m[1])
+			mo, _ := strconv.Atoi(m[2])
+			d, _ := strconv.Atoi(m[3])
+			e.setDate(y, time.Month(mo), d)
+		case isMonth(word):
+			// "nov 3", "november 3rd"
+			m := dayNumber.FindStringSubmatch(next)
+			if m == nil {
+				return nil, fmt.Errorf("expected a day after %q", word)
+			}
+			d, _ := strconv.Atoi(m[1])
+			e.setDate(0, months[word], d)
+			i++
+		case dayNumber.MatchString(word) && (isMonth(next) || next == "of" && i+2 < len(fields) && isMonth(strings.ToLower(fields[i+2]))):
+			// "3 nov", "3rd of november"
+			d, _ := strconv.Atoi(dayNumber.FindStringSubmatch(word)[1])
+			if next == "of" {
+				i++
+			}
+			e.setDate(0, months[strings.ToLower(fields[i+1])], d)
+			i++
+		case clock.MatchString(word):
+			if next == "am" || next == "pm" {
+				word += next
+				i++
+			}
+			h, m, err := parseClock(word)
+			if err != nil {
+				return nil, err
+			}
+			e.setClock(h, m)
+		default:
+			loc, err := ParseZone(fields[i])
+			if err != nil {
+				return nil, fmt.Errorf("could not understand %q", fields[i])
+			}
+			e.loc = loc
+		}
+	}
+
+	return e, nil
+}
+
+func parseClock(s string) (int, int, error) {
+	m := clock.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, _ := strconv.Atoi(m[1])
+	min := 0
+	if m[2] != "" {
+		min, _ = strconv.Atoi(m[2])
+	}
+
+	switch m[3] {
+	case "am", "pm":
+		if h < 1 || h > 12 {
+			return 0, 0, fmt.Errorf("invalid time %q", s)
+		}
+		h %= 12
+		if m[3] == "pm" {
+			h += 12
+		}
+	default:
+		if h > 23 {
+			return 0, 0, fmt.Errorf("invalid time %q", s)
+		}
+	}
+	if min > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	return h, min, nil
+}
+
+func (e *expr) setOffset(days int) {
+	e.hasOffset = true
+	e.dayOffset = days
+}
+
+func (e *expr) setWeekday(wd time.Weekday, skipToday bool) {
+	e.hasWeekday = true
+	e.weekday = wd
+	e.skipToday = skipToday
+}
+
+func (e *expr) setDate(y int, m time.Month, d int) {
+	e.hasDate = true
+	e.year, e.month, e.day = y, m, d
+}
+
+func (e *expr) setClock(h, m int) {
+	e.hasClock = true
+	e.hour, e.minute = h, m
+}
+
+// resolve turns the expression into the earliest matching time that is not in the past
+func (e *expr) resolve(now time.Time, loc *time.Location) time.Time {
+	if e.loc != nil {
+		loc = e.loc
+	}
+	local := now.In(loc)
+	y, m, d := local.Date()
+
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, e.hour, e.minute, 0, 0, loc)
+	}
+
+	switch {
+	case e.hasDate:
+		if e.year != 0 {
+			return at(e.year, e.month, e.day)
+		}
+		t := at(y, e.month, e.day)
+		if t.Before(now) && !sameDay(t, local) {
+			t = at(y+1, e.month, e.day)
+		}
+		return t
+	case e.hasWeekday:
+		days := (int(e.weekday) - int(local.Weekday()) + 7) % 7
+		t := at(y, m, d+days)
+		if days == 0 && (e.skipToday || t.Before(now)) {
+			t = at(y, m, d+7)
+		}
+		return t
+	case e.hasOffset:
+		return at(y, m, d+e.dayOffset)
+	default:
+		t := at(y, m, d)
+		// a bare time of day that already passed means tomorrow
+		if t.Before(now) {
+			t = at(y, m, d+1)
+		}
+		return t
+	}
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+func isWeekday(s string) bool {
+	_, ok := weekdays[s]
+	return ok
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January,
+	"feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March,
+	"apr": time.April, "april": time.April,
+	"may": time.May,
+	"jun": time.June, "june": time.June,
+	"jul": time.July, "july": time.July,
+	"aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September,
+	"oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November,
+	"dec": time.December, "december": time.December,
+}
+
+func isMonth(s string) bool {
+	_, ok := months[s]
+	return ok
+}
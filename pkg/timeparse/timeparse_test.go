@@ -0,0 +1,180 @@
+package timeparse
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustZone(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	berlin := mustZone(t, "Europe/Berlin")
+	ny := mustZone(t, "America/New_York")
+	// a wednesday afternoon
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		loc   *time.Location
+		want  time.Time
+		err   error
+	}{
+		{"now", time.UTC, now, nil},
+		{"in 2h", time.UTC, now.Add(time.Hour * 2), nil},
+		{"in 1 hour 30 minutes", time.UTC, now.Add(time.Minute * 90), nil},
+		{"2026-11-01T20:00:00Z", time.UTC, time.Date(2026, 11, 1, 20, 0, 0, 0, time.UTC), nil},
+		{"1630006096", time.UTC, time.Unix(1630006096, 0), nil},
+		{"20:00", time.UTC, time.Date(2026, 10, 14, 20, 0, 0, 0, time.UTC), nil},
+		// a time of day that passed means tomorrow
+		{"9am", time.UTC, time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC), nil},
+		{"tomorrow 8pm", time.UTC, time.Date(2026, 10, 15, 20, 0, 0, 0, time.UTC), nil},
+		{"tomorrow 8 pm EST", time.UTC, time.Date(2026, 10, 15, 20, 0, 0, 0, ny), nil},
+		{"tonight", time.UTC, time.Date(2026, 10, 14, 20, 0, 0, 0, time.UTC), nil},
+		{"friday 20:00 CET", time.UTC, time.Date(2026, 10, 16, 20, 0, 0, 0, berlin), nil},
+		{"friday 20:00 Europe/Berlin", time.UTC, time.Date(2026, 10, 16, 20, 0, 0, 0, berlin), nil},
+		{"next sat noon", time.UTC, time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC), nil},
+		// today's weekday is this week until it passed, next always skips today
+		{"wednesday 18:00", time.UTC, time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC), nil},
+		{"wednesday 10:00", time.UTC, time.Date(2026, 10, 21, 10, 0, 0, 0, time.UTC), nil},
+		{"next wed 18:00", time.UTC, time.Date(2026, 10, 21, 18, 0, 0, 0, time.UTC), nil},
+		{"nov 3rd 18:30", berlin, time.Date(2026, 11, 3, 18, 30, 0, 0, berlin), nil},
+		{"3rd of november at 18:30", berlin, time.Date(2026, 11, 3, 18, 30, 0, 0, berlin), nil},
+		// dates that passed this year mean next year
+		{"march 1 noon", time.UTC, time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC), nil},
+		{"2027-01-02 midnight", time.UTC, time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC), nil},
+		{"20:00 UTC+2", time.UTC, time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC), nil},
+		{"", time.UTC, time.Time{}, ErrEmpty},
+		{"tomorrow", time.UTC, time.Time{}, ErrMissingTime},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input, now, tt.loc)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.input, err, tt.err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	for _, input := range []string{"25:00", "13pm", "20:61", "next week", "nov", "blah 20:00", "../etc 20:00", "20:00 Local"} {
+		if got, err := Parse(input, now, time.UTC); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", input, got)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	berlin := mustZone(t, "Europe/Berlin")
+	now := time.Date(2026, 10, 14, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		input string
+		loc   *time.Location
+		want  time.Time
+		err   error
+	}{
+		{"2026-11-01", time.UTC, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), nil},
+		{"today", time.UTC, time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), nil},
+		// it is already thursday in berlin
+		{"today", berlin, time.Date(2026, 10, 15, 0, 0, 0, 0, berlin), nil},
+		{"tomorrow", time.UTC, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), nil},
+		{"wednesday", time.UTC, time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), nil},
+		{"next wednesday", time.UTC, time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC), nil},
+		{"friday", time.UTC, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), nil},
+		{"nov 3", time.UTC, time.Date(2026, 11, 3, 0, 0, 0, 0, time.UTC), nil},
+		{"", time.UTC, time.Time{}, ErrEmpty},
+		{"friday 20:00", time.UTC, time.Time{}, ErrUnexpectedTime},
+		{"CET", time.UTC, time.Time{}, ErrMissingDate},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.input, now, tt.loc)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseDate(%q) error = %v, want %v", tt.input, err, tt.err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+		ok    bool
+	}{
+		{"2h5m", time.Hour*2 + time.Minute*5, true},
+		{"90m", time.Minute * 90, true},
+		{"2 hours 5 minutes", time.Hour*2 + time.Minute*5, true},
+		{"1 hour and 30 mins", time.Minute * 90, true},
+		{"2 days", time.Hour * 48, true},
+		{"3d, 4h", time.Hour * 76, true},
+		{"", 0, false},
+		{"soon", 0, false},
+		{"2 weeks", 0, false},
+		{"0 hours", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseDuration(%q) error = %v, want ok %v", tt.input, err, tt.ok)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseZone(t *testing.T) {
+	tests := []struct {
+		input  string
+		name   string
+		offset int
+		ok     bool
+	}{
+		{"UTC", "UTC", 0, true},
+		{"est", "America/New_York", 0, true},
+		{"CEST", "Europe/Berlin", 0, true},
+		{"Asia/Tokyo", "Asia/Tokyo", 0, true},
+		{"UTC+2", "UTC+2", 2 * 3600, true},
+		{"+05:30", "+05:30", 5*3600 + 30*60, true},
+		{"gmt-0330", "GMT-0330", -(3*3600 + 30*60), true},
+		{"UTC+15", "", 0, false},
+		{"+02:75", "", 0, false},
+		{"Local", "", 0, false},
+		{"/etc/localtime", "", 0, false},
+		{"../../etc/passwd", "", 0, false},
+		{"Mars/Olympus", "", 0, false},
+	}
+	for _, tt := range tests {
+		loc, err := ParseZone(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseZone(%q) error = %v, want ok %v", tt.input, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if loc.String() != tt.name {
+			t.Errorf("ParseZone(%q) = %v, want %v", tt.input, loc, tt.name)
+		}
+		if _, offset := time.Date(2026, 1, 1, 0, 0, 0, 0, loc).Zone(); tt.offset != 0 && offset != tt.offset {
+			t.Errorf("ParseZone(%q) has offset %d, want %d", tt.input, offset, tt.offset)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package timeparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zones maps common abbreviations to the region they are used in. Abbreviations
+// name the region rather than a fixed offset, because people write "EST" all
+// year round and mean whatever time it is in New York.
+var zones = map[string]string{
+	"utc": "UTC", "gmt": "UTC", "z": "UTC",
+	"et": "America/New_York", "est": "America/New_York", "edt": "America/New_York",
+	"ct": "America/Chicago", "cst": "America/Chicago", "cdt": "America/Chicago",
+	"mt": "America/Denver", "mst": "America/Denver", "mdt": "America/Denver",
+	"pt": "America/Los_Angeles", "pst": "America/Los_Angeles", "pdt": "America/Los_Angeles",
+	"akst": "America/Anchorage", "akdt": "America/Anchorage",
+	"hst": "Pacific/Honolulu",
+	"brt": "America/Sao_Paulo",
+	"art": "America/Argentina/Buenos_Aires",
+	"wet": "Europe/Lisbon", "west": "Europe/Lisbon",
+	"bst": "Europe/London", "uk": "Europe/London",
+	"cet": "Europe/Berlin", "cest": "Europe/Berlin",
+	"eet": "Europe/Helsinki", "eest": "Europe/Helsinki",
+	"msk":  "Europe/Moscow",
+	"ist":  "Asia/Kolkata",
+	"sgt":  "Asia/Singapore",
+	"hkt":  "Asia/Hong_Kong",
+	"jst":  "Asia/Tokyo",
+	"kst":  "Asia/Seoul",
+	"awst": "Australia/Perth",
+	"acst": "Australia/Adelaide", "acdt": "Australia/Adelaide",
+	"aest": "Australia/Sydney", "aedt": "Australia/Sydney",
+	"nzst": "Pacific/Auckland", "nzdt": "Pacific/Auckland",
+}
+
+// ParseZone accepts IANA names ("Europe/Berlin"), common abbreviations ("CET")
+// and utc offsets ("UTC+2", "+05:30")
+func ParseZone(s string) (*time.Location, error) {
+	lower := strings.ToLower(s)
+	if name, ok := zones[lower]; ok {
+		return time.LoadLocation(name)
+	}
+
+	if m := offset.FindStringSubmatch(lower); m != nil {
+		h, _ := strconv.Atoi(m[2])
+		min := 0
+		if m[3] != "" {
+			min, _ = strconv.Atoi(m[3])
+		}
+		if h > 14 || min > 59 {
+			return nil, fmt.Errorf("invalid utc offset %q", s)
+		}
+		secs := h*3600 + min*60
+		if m[1] == "-" {
+			secs = -secs
+		}
+		return time.FixedZone(strings.ToUpper(s), secs), nil
+	}
+
+	// time.LoadLocation would accept file paths and "Local"
+	if !strings.Contains(s, "/") || strings.Contains(s, "..") || strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("unknown timezone %q", s)
+	}
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", s)
+	}
+
+	return loc, nil
+}